@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"adcentra.ai/internal/accesstoken"
+	"adcentra.ai/internal/data"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+// newAccessTokenKeySet returns nil when no keys are configured, in which case only opaque
+// access tokens are issued and accepted.
+func newAccessTokenKeySet(cfg config) (*accesstoken.KeySet, error) {
+	var signing *accesstoken.Key
+	if cfg.accessTokens.signingKey != "" {
+		key, err := accesstoken.ParseSigningKey(cfg.accessTokens.signingKey)
+		if err != nil {
+			return nil, err
+		}
+		signing = &key
+	}
+
+	verification := make([]accesstoken.Key, 0, len(cfg.accessTokens.verificationKeys))
+	for _, s := range cfg.accessTokens.verificationKeys {
+		key, err := accesstoken.ParseVerificationKey(s)
+		if err != nil {
+			return nil, err
+		}
+		verification = append(verification, key)
+	}
+
+	if signing == nil && len(verification) == 0 {
+		return nil, nil
+	}
+
+	return accesstoken.NewKeySet(signing, verification...), nil
+}
+
+// userPermissionsAndRoles returns the permissions and roles of the user, going through the cache.
+func (app *application) userPermissionsAndRoles(ctx context.Context, userID int64) (data.Permissions, data.Roles, error) {
+	var err error
+
+	permissions := app.models.Permissions.GetAllForUserCached(ctx, userID)
+	if permissions == nil {
+		permissions, err = app.models.Permissions.GetAllForUser(ctx, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		app.models.Permissions.SetAllForUserToCache(ctx, userID, permissions)
+	}
+
+	roles := app.models.Roles.GetAllForUserCached(ctx, userID)
+	if roles == nil {
+		roles, err = app.models.Roles.GetAllForUser(ctx, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		app.models.Roles.SetAllForUserToCache(ctx, userID, roles)
+	}
+
+	return permissions, roles, nil
+}
+
+// newAuthenticationToken issues the access token for a session. With a signing key
+// configured it is a signed token carrying the user's roles and permissions, which is
+// never stored; otherwise it is an opaque token in the tokens table.
+func (app *application) newAuthenticationToken(ctx context.Context, session *data.Session, user *data.User, family []byte) (*data.Token, error) {
+	if !app.accessTokens.CanSign() {
+		return app.models.Tokens.NewForSession(ctx, session, authTokenTTL, data.ScopeAuthentication, family)
+	}
+
+	permissions, roles, err := app.userPermissionsAndRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiry := now.Add(authTokenTTL)
+
+	plaintext, err := app.accessTokens.Sign(accesstoken.Claims{
+		Subject:     strconv.FormatInt(user.ID, 10),
+		SessionID:   session.ID,
+		Email:       user.Email,
+		Activated:   user.Activated,
+		Roles:       roles.ToStrings(),
+		Permissions: permissions.ToStrings(),
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   expiry.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &data.Token{
+		Plaintext: plaintext,
+		UserID:    user.ID,
+		Expiry:    pgtype.Timestamptz{Time: expiry, Valid: true},
+		Scope:     data.ScopeAuthentication,
+	}, nil
+}
+
+// sessionFromAccessToken verifies a signed access token and builds the request session
+// from its claims alone, without touching the database.
+func (app *application) sessionFromAccessToken(tokenPlainText string) (*data.Session, error) {
+	claims, err := app.accessTokens.Verify(tokenPlainText, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, accesstoken.ErrInvalidToken
+	}
+
+	var permissions data.Permissions
+	permissions.FromStrings(claims.Permissions)
+	permissionMap := make(map[data.PermCode]bool)
+	for _, permission := range permissions {
+		permissionMap[permission] = true
+	}
+
+	var roles data.Roles
+	roles.FromStrings(claims.Roles)
+
+	return &data.Session{
+		ID:     claims.SessionID,
+		UserID: userID,
+		User: &data.User{
+			ID:        userID,
+			Email:     claims.Email,
+			Activated: claims.Activated,
+		},
+		Roles:         roles,
+		PermissionMap: permissionMap,
+		Stateless:     true,
+	}, nil
+}
+
+// currentUser returns the full record of the authenticated user. Sessions built from a
+// signed access token only carry part of the user, so it is loaded on demand.
+func (app *application) currentUser(ctx context.Context, e echo.Context) (*data.User, error) {
+	session := app.contextGetSession(e)
+	if !session.Stateless {
+		return session.User, nil
+	}
+
+	return app.models.Users.GetByID(ctx, session.UserID)
+}
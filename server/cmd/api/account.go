@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/validator"
+	"github.com/labstack/echo/v4"
+)
+
+func (app *application) deleteCurrentUser(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	// Deleting the account takes the password, which API keys never know about.
+	if app.contextGetSession(e).APIKey != nil {
+		return app.notPermittedResponse(e)
+	}
+
+	var input struct {
+		Password string `json:"password"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	v := validator.New()
+
+	if v.Check(validator.NotBlank(input.Password), "password", i18n.LocalizeMessage(localizer, "PasswordRequired", nil)); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	user, err := app.currentUser(ctx, e)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	err = app.verifyCurrentPassword(ctx, e, user, input.Password)
+	if err != nil {
+		return err
+	}
+
+	err = app.models.Users.MarkForDeletion(ctx, user)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.models.Permissions.InvalidateAllForUserCache(ctx, user.ID)
+	app.models.Roles.InvalidateAllForUserCache(ctx, user.ID)
+
+	deleteAt := user.DeletionRequestedAt.Time.Add(app.config.accountDeletion.gracePeriod)
+
+	app.audit(ctx, e, data.AuditDeletionRequested, user.ID, user.ID, map[string]any{"delete_at": deleteAt})
+
+	app.sendSecurityEmail(e, user, "account_deletion.tmpl", map[string]any{
+		"deleteAt": deleteAt.UTC().Format(time.RFC1123),
+	})
+
+	return e.JSON(http.StatusAccepted, echo.Map{
+		"message":   i18n.LocalizeMessage(localizer, "AccountDeletionScheduled", nil),
+		"delete_at": deleteAt,
+	})
+}
+
+// exportCurrentUser returns everything stored about the user as a downloadable JSON document.
+func (app *application) exportCurrentUser(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	user, err := app.currentUser(ctx, e)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	permissions, roles, err := app.userPermissionsAndRoles(ctx, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	sessions, err := app.models.Sessions.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	identities, err := app.models.Identities.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	apiKeys, err := app.models.APIKeys.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	twoFactorEnabled, err := app.models.TwoFactor.IsEnabledForUser(ctx, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	auditEvents, err := app.models.Audit.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	e.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="adcentra-export-%d.json"`, user.ID))
+	return e.JSON(http.StatusOK, echo.Map{
+		"exported_at":        time.Now(),
+		"user":               user,
+		"created_at":         user.CreatedAt,
+		"roles":              roles,
+		"permissions":        permissions,
+		"sessions":           sessions,
+		"identities":         identities,
+		"api_keys":           apiKeys,
+		"two_factor_enabled": twoFactorEnabled,
+		"audit_events":       auditEvents,
+	})
+}
+
+// PurgeDeletedUsers permanently deletes accounts whose deletion grace period has passed.
+func (app *application) PurgeDeletedUsers() {
+	for {
+		select {
+		case <-app.serverCtx.Done():
+			return
+		default:
+			startTime := time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+
+			deleted, err := app.models.Users.DeletePendingDeletion(ctx, app.config.accountDeletion.gracePeriod)
+			if err != nil {
+				app.logInternalError("app.models.Users.DeletePendingDeletion failed", err)
+			} else if deleted > 0 {
+				app.logger.Info("deleted accounts past their grace period", "count", deleted)
+			}
+			cancel()
+
+			timer := time.NewTimer(time.Until(startTime.Add(app.config.cleanup.deletedUsersPurgePeriod)))
+			select {
+			case <-app.serverCtx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				// Continue with the next iteration
+			}
+		}
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/validator"
+	"github.com/labstack/echo/v4"
+)
+
+func (app *application) listRoles(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	roles, err := app.models.Roles.GetAll(ctx)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{"roles": roles})
+}
+
+func (app *application) createRole(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var input struct {
+		Code        string   `json:"code"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	def := &data.RoleDefinition{
+		Code:        data.Role(input.Code),
+		Description: input.Description,
+		Permissions: data.Permissions{},
+	}
+	def.Permissions.FromStrings(input.Permissions)
+
+	v := validator.New()
+
+	data.ValidateRoleDefinition(v, localizer, def)
+
+	if len(def.Permissions) > 0 {
+		catalog, err := app.models.Permissions.GetAll(ctx)
+		if err != nil {
+			return app.serverErrorResponse(e, err)
+		}
+		data.ValidatePermissionCodes(v, localizer, def.Permissions, catalog)
+	}
+
+	if !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	session := app.contextGetSession(e)
+	if !data.CanGrantPermissions(session.Roles, session.Permissions(), def.Permissions) {
+		return app.notPermittedResponse(e)
+	}
+
+	err := app.models.Roles.Insert(ctx, def)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRoleCode):
+			v.AddError("code", i18n.LocalizeMessage(localizer, "RoleAlreadyExists", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	return e.JSON(http.StatusCreated, echo.Map{"role": def})
+}
+
+func (app *application) showRole(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	def, err := app.models.Roles.Get(ctx, data.Role(e.Param("code")))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{"role": def})
+}
+
+// updateRole renames or describes a role. System roles are referenced from code, so they
+// can only be described.
+func (app *application) updateRole(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var input struct {
+		Code        *string `json:"code"`
+		Description *string `json:"description"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	role := data.Role(e.Param("code"))
+
+	def, err := app.models.Roles.Get(ctx, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	err = app.requireGrantableRole(ctx, e, role)
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+
+	if input.Code != nil {
+		if def.System && data.Role(*input.Code) != role {
+			v.AddError("code", i18n.LocalizeMessage(localizer, "SystemRoleCannotBeRenamed", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		}
+		def.Code = data.Role(*input.Code)
+	}
+	if input.Description != nil {
+		def.Description = *input.Description
+	}
+
+	if data.ValidateRoleDefinition(v, localizer, def); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	err = app.models.Roles.Update(ctx, role, def)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		case errors.Is(err, data.ErrDuplicateRoleCode):
+			v.AddError("code", i18n.LocalizeMessage(localizer, "RoleAlreadyExists", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{"role": def})
+}
+
+func (app *application) deleteRole(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	role := data.Role(e.Param("code"))
+
+	def, err := app.models.Roles.Get(ctx, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	if def.System {
+		return app.systemRoleResponse(e)
+	}
+
+	err = app.requireGrantableRole(ctx, e, role)
+	if err != nil {
+		return err
+	}
+
+	err = app.models.Roles.Delete(ctx, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		case errors.Is(err, data.ErrRoleInUse):
+			return app.roleInUseResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	return e.NoContent(http.StatusOK)
+}
+
+func (app *application) listPermissions(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	permissions, err := app.models.Permissions.GetAll(ctx)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{"permissions": permissions})
+}
+
+func (app *application) listRolePermissions(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	role, err := app.readRoleParam(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	permissions, err := app.models.Permissions.GetAllForRoleCode(ctx, role)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{"permissions": permissions})
+}
+
+func (app *application) addRolePermissions(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var input struct {
+		Permissions []string `json:"permissions"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	role, err := app.readRoleParam(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	catalog, err := app.models.Permissions.GetAll(ctx)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	var permissions data.Permissions
+	permissions.FromStrings(input.Permissions)
+
+	v := validator.New()
+
+	if data.ValidatePermissionCodes(v, localizer, permissions, catalog); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	// Like stripping a role, extending it is limited to roles the admin could grant, and
+	// only with permissions they hold themselves.
+	err = app.requireGrantableRole(ctx, e, role)
+	if err != nil {
+		return err
+	}
+
+	session := app.contextGetSession(e)
+	if !data.CanGrantPermissions(session.Roles, session.Permissions(), permissions) {
+		return app.notPermittedResponse(e)
+	}
+
+	err = app.models.Permissions.AddForRole(ctx, role, permissions)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.audit(ctx, e, data.AuditRolePermissionsAdded, session.UserID, 0, map[string]any{
+		"role":        role,
+		"permissions": permissions,
+	})
+
+	permissions, err = app.models.Permissions.GetAllForRoleCode(ctx, role)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{"permissions": permissions})
+}
+
+func (app *application) removeRolePermission(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	role, err := app.readRoleParam(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	// Stripping a role is held to the same rule as granting it, so that admins cannot weaken
+	// the roles above theirs.
+	err = app.requireGrantableRole(ctx, e, role)
+	if err != nil {
+		return err
+	}
+
+	permissions := data.Permissions{data.PermCode(e.Param("permission"))}
+
+	err = app.models.Permissions.RemoveForRole(ctx, role, permissions)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	app.audit(ctx, e, data.AuditRolePermissionsRemoved, app.contextGetSession(e).UserID, 0, map[string]any{
+		"role":        role,
+		"permissions": permissions,
+	})
+
+	return e.NoContent(http.StatusOK)
+}
+
+func (app *application) listRoleUsers(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	role, err := app.readRoleParam(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+	qs := e.QueryParams()
+
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, v, localizer),
+		PageSize:     app.readInt(qs, "page_size", 20, v, localizer),
+		Sort:         app.readString(qs, "sort", "id"),
+		SortSafeList: []string{"id", "full_name", "email", "created_at", "-id", "-full_name", "-email", "-created_at"},
+	}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	users, metadata, err := app.models.Roles.ListUsersWithRole(ctx, role, filters)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{
+		"users":    users,
+		"metadata": metadata,
+	})
+}
+
+func (app *application) addRoleUser(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var input struct {
+		UserID int64 `json:"user_id"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	role, err := app.readRoleParam(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	err = app.requireGrantableRole(ctx, e, role)
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+
+	user, err := app.models.Users.GetByID(ctx, input.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("user_id", i18n.LocalizeMessage(localizer, "UserNotFound", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	err = app.models.Roles.AddForUser(ctx, user.ID, data.Roles{role})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUniqueConstraint):
+			v.AddError("user_id", i18n.LocalizeMessage(localizer, "UserAlreadyHasRole", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	app.audit(ctx, e, data.AuditRoleGranted, app.contextGetSession(e).UserID, user.ID, map[string]any{"role": role})
+	app.notify(e, user, "role_changed.tmpl", map[string]any{"role": role, "granted": true})
+
+	return e.NoContent(http.StatusCreated)
+}
+
+func (app *application) removeRoleUser(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	role, err := app.readRoleParam(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	// Revoking is held to the same rule as granting, so that admins cannot demote superadmins.
+	err = app.requireGrantableRole(ctx, e, role)
+	if err != nil {
+		return err
+	}
+
+	id, err := app.readIDParam(e)
+	if err != nil {
+		return app.notFoundResponse(e)
+	}
+
+	user, err := app.models.Users.GetByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	err = app.models.Roles.RemoveForUser(ctx, user.ID, data.Roles{role})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	app.audit(ctx, e, data.AuditRoleRevoked, app.contextGetSession(e).UserID, user.ID, map[string]any{"role": role})
+	app.notify(e, user, "role_changed.tmpl", map[string]any{"role": role, "granted": false})
+
+	return e.NoContent(http.StatusOK)
+}
+
+// readRoleParam returns the role named by the code route parameter. On failure the returned
+// error is the response to send.
+func (app *application) readRoleParam(ctx context.Context, e echo.Context) (data.Role, error) {
+	role := data.Role(e.Param("code"))
+
+	err := app.models.Roles.Exists(ctx, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return "", app.notFoundResponse(e)
+		default:
+			return "", app.serverErrorResponse(e, err)
+		}
+	}
+
+	return role, nil
+}
+
+// requireGrantableRole checks that the authenticated user may grant or revoke the role. On
+// failure the returned error is the response to send.
+func (app *application) requireGrantableRole(ctx context.Context, e echo.Context, role data.Role) error {
+	rolePermissions, err := app.models.Permissions.GetAllForRoleCode(ctx, role)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	session := app.contextGetSession(e)
+	if !data.CanGrantRole(session.Roles, session.Permissions(), role, rolePermissions) {
+		return app.notPermittedResponse(e)
+	}
+
+	return nil
+}
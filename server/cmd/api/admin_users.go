@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/policy"
+	"adcentra.ai/internal/validator"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+func (app *application) listUsers(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	v := validator.New()
+	qs := e.QueryParams()
+
+	search := app.readString(qs, "search", "")
+	activated := app.readBool(qs, "activated", v, localizer)
+
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, v, localizer),
+		PageSize:     app.readInt(qs, "page_size", 20, v, localizer),
+		Sort:         app.readString(qs, "sort", "id"),
+		SortSafeList: []string{"id", "full_name", "email", "created_at", "last_login_at", "-id", "-full_name", "-email", "-created_at", "-last_login_at"},
+	}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	users, metadata, err := app.models.Users.GetAll(ctx, search, activated, filters)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{
+		"users":    users,
+		"metadata": metadata,
+	})
+}
+
+func (app *application) showUser(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	user, err := app.readUserParam(ctx, e, policy.ViewUser)
+	if err != nil {
+		return err
+	}
+
+	roles, err := app.models.Roles.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	sessions, err := app.models.Sessions.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	e.Response().Header().Set("ETag", versionETag(user.Version))
+	return e.JSON(http.StatusOK, echo.Map{
+		"user":     user,
+		"roles":    roles,
+		"sessions": sessions,
+	})
+}
+
+func (app *application) updateUser(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var input struct {
+		FullName        *string `json:"full_name"`
+		ProfileImageURL *string `json:"profile_image_url"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	user, err := app.readUserParam(ctx, e, policy.ManageUser)
+	if err != nil {
+		return err
+	}
+
+	if !ifMatch(e, versionETag(user.Version)) {
+		return app.preconditionFailedResponse(e)
+	}
+
+	if input.FullName != nil {
+		user.FullName = *input.FullName
+	}
+	if input.ProfileImageURL != nil {
+		// An empty URL removes the profile image.
+		user.ProfileImageURL = pgtype.Text{String: *input.ProfileImageURL, Valid: *input.ProfileImageURL != ""}
+	}
+
+	v := validator.New()
+
+	if data.ValidateUser(v, localizer, user); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	err = app.models.Users.Update(ctx, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return app.editConflictResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	e.Response().Header().Set("ETag", versionETag(user.Version))
+	return e.JSON(http.StatusOK, echo.Map{
+		"user": user,
+	})
+}
+
+// updateUserActivation activates or deactivates the account. Deactivating disables it rather
+// than undoing the activation: disabled users can't log in, refresh or use their API keys,
+// and are signed out everywhere. Activating enables the account again, activating it too if
+// the user never did.
+func (app *application) updateUserActivation(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var input struct {
+		Activated *bool `json:"activated"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	v := validator.New()
+
+	if v.Check(input.Activated != nil, "activated", i18n.LocalizeMessage(localizer, "ActivatedMustBeProvided", nil)); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	user, err := app.readUserParam(ctx, e, policy.ManageUserAccount)
+	if err != nil {
+		return err
+	}
+
+	if !ifMatch(e, versionETag(user.Version)) {
+		return app.preconditionFailedResponse(e)
+	}
+
+	action := data.AuditUserActivated
+	if *input.Activated {
+		user.Activated = true
+		user.Disabled = false
+		err = app.models.Users.Update(ctx, user)
+	} else {
+		action = data.AuditUserDeactivated
+		err = app.models.Users.Disable(ctx, user)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return app.editConflictResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	app.audit(ctx, e, action, app.contextGetSession(e).UserID, user.ID, nil)
+
+	e.Response().Header().Set("ETag", versionETag(user.Version))
+	return e.JSON(http.StatusOK, echo.Map{
+		"user": user,
+	})
+}
+
+// deleteUserSessions signs the user out of every device. Signed access tokens cannot be
+// revoked and stay valid until they expire, but they can no longer be refreshed.
+func (app *application) deleteUserSessions(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	user, err := app.readUserParam(ctx, e, policy.RevokeUserSessions)
+	if err != nil {
+		return err
+	}
+
+	err = app.models.Sessions.DeleteAllForUserExcept(ctx, user.ID, 0)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.audit(ctx, e, data.AuditSessionsRevoked, app.contextGetSession(e).UserID, user.ID, nil)
+	app.notify(e, user, "sessions_revoked.tmpl", nil)
+
+	return e.JSON(http.StatusOK, echo.Map{"message": i18n.LocalizeMessage(localizer, "UserSessionsRevoked", nil)})
+}
+
+// createUserPasswordResetToken sends the user the same password reset email they would
+// get by requesting one themselves.
+func (app *application) createUserPasswordResetToken(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 6*time.Second)
+	defer cancel()
+
+	user, err := app.readUserParam(ctx, e, policy.ManageUserAccount)
+	if err != nil {
+		return err
+	}
+
+	if !user.Activated {
+		v := validator.New()
+		v.AddError("email", i18n.LocalizeMessage(localizer, "AccountNotActivated", nil))
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	token, err := app.models.Tokens.New(ctx, user.ID, passwordResetTokenTTL, data.ScopePasswordReset)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.audit(ctx, e, data.AuditPasswordResetSent, app.contextGetSession(e).UserID, user.ID, nil)
+
+	app.sendSecurityEmail(e, user, "password_reset_token.tmpl", map[string]any{
+		"passwordResetToken": token.Plaintext,
+	})
+
+	return e.JSON(http.StatusAccepted, echo.Map{
+		"message": i18n.LocalizeMessage(localizer, "PasswordResetTokenCreated", nil),
+	})
+}
+
+// readUserParam loads the user named by the id route parameter and checks that the action
+// is allowed on them. On failure the returned error is the response to send.
+func (app *application) readUserParam(ctx context.Context, e echo.Context, action policy.Action) (*data.User, error) {
+	id, err := app.readIDParam(e)
+	if err != nil {
+		return nil, app.notFoundResponse(e)
+	}
+
+	user, err := app.models.Users.GetByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, app.notFoundResponse(e)
+		default:
+			return nil, app.serverErrorResponse(e, err)
+		}
+	}
+
+	resource, err := app.userResource(ctx, user)
+	if err != nil {
+		return nil, app.serverErrorResponse(e, err)
+	}
+
+	err = app.authorize(ctx, e, action, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
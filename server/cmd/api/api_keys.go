@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/validator"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+// sessionFromAPIKey builds the request session for an API key. Scoped keys only carry the
+// permissions they were granted and never act with the owner's roles, so that a scoped key
+// of a superadmin does not bypass permission checks.
+func (app *application) sessionFromAPIKey(ctx context.Context, plaintext string) (*data.Session, error) {
+	key, user, err := app.models.APIKeys.GetForPlaintext(ctx, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, roles, err := app.userPermissionsAndRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.Scoped() {
+		if roles.Has(data.RoleSuperAdmin) {
+			permissions = key.Permissions
+		} else {
+			permissions = key.Restrict(permissions)
+		}
+		roles = nil
+	}
+
+	permissionMap := make(map[data.PermCode]bool)
+	for _, permission := range permissions {
+		permissionMap[permission] = true
+	}
+
+	err = app.models.APIKeys.Touch(ctx, key.ID)
+	if err != nil {
+		// Failing to record the last use should not fail the request.
+		app.logger.Error("failed to record api key use", "api_key_id", key.ID, "error", err.Error())
+	}
+
+	return &data.Session{
+		UserID:        user.ID,
+		User:          user,
+		Roles:         roles,
+		PermissionMap: permissionMap,
+		APIKey:        key,
+	}, nil
+}
+
+func (app *application) listCurrentUserAPIKeys(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	session := app.contextGetSession(e)
+
+	keys, err := app.models.APIKeys.GetAllForUser(ctx, session.User.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{"api_keys": keys})
+}
+
+func (app *application) createCurrentUserAPIKey(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	session := app.contextGetSession(e)
+
+	// Otherwise a scoped key could mint itself an unscoped one.
+	if session.APIKey != nil {
+		return app.notPermittedResponse(e)
+	}
+
+	var input struct {
+		Name        string     `json:"name"`
+		Permissions []string   `json:"permissions"`
+		ExpiresAt   *time.Time `json:"expires_at"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	key := &data.APIKey{Name: input.Name}
+	if input.Permissions != nil {
+		key.Permissions = data.Permissions{}
+		key.Permissions.FromStrings(input.Permissions)
+	}
+	if input.ExpiresAt != nil {
+		key.ExpiresAt = pgtype.Timestamptz{Time: *input.ExpiresAt, Valid: true}
+	}
+
+	permissions, roles, err := app.userPermissionsAndRoles(ctx, session.User.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	v := validator.New()
+
+	if data.ValidateAPIKey(v, localizer, key, permissions, roles); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	key, err = app.models.APIKeys.New(ctx, session.User.ID, key.Name, key.Permissions, key.ExpiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateAPIKeyName):
+			v.AddError("name", i18n.LocalizeMessage(localizer, "APIKeyNameAlreadyExists", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	app.audit(ctx, e, data.AuditAPIKeyCreated, session.User.ID, session.User.ID, map[string]any{
+		"api_key_id":  key.ID,
+		"name":        key.Name,
+		"permissions": key.Permissions,
+	})
+
+	// The plaintext key is only ever returned here.
+	return e.JSON(http.StatusCreated, echo.Map{"api_key": key})
+}
+
+func (app *application) deleteCurrentUserAPIKey(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	id, err := app.readIDParam(e)
+	if err != nil {
+		return app.notFoundResponse(e)
+	}
+
+	session := app.contextGetSession(e)
+
+	err = app.models.APIKeys.Delete(ctx, id, session.User.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	app.audit(ctx, e, data.AuditAPIKeyRevoked, session.User.ID, session.User.ID, map[string]any{"api_key_id": id})
+
+	return e.NoContent(http.StatusOK)
+}
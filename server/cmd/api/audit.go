@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// audit records a security-relevant event with the IP and user agent of the request.
+// actorID is the user who performed the action and targetID the one it was performed on;
+// 0 stands for nobody. Failing to record an event is logged rather than failing the request.
+func (app *application) audit(ctx context.Context, e echo.Context, action data.AuditAction, actorID, targetID int64, metadata map[string]any) {
+	err := app.models.Audit.Insert(ctx, &data.AuditEvent{
+		ActorID:   data.AuditUserID(actorID),
+		TargetID:  data.AuditUserID(targetID),
+		Action:    action,
+		IP:        e.RealIP(),
+		UserAgent: e.Request().UserAgent(),
+		Metadata:  metadata,
+	})
+	if err != nil {
+		app.logger.Error("failed to record audit event", "action", action, "error", err.Error())
+	}
+}
+
+func (app *application) listAuditEvents(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	v := validator.New()
+	qs := e.QueryParams()
+
+	filter := data.AuditFilter{
+		ActorID:  int64(app.readInt(qs, "actor_id", 0, v, localizer)),
+		TargetID: int64(app.readInt(qs, "target_id", 0, v, localizer)),
+		Action:   data.AuditAction(app.readString(qs, "action", "")),
+		Since:    app.readTime(qs, "since", v, localizer),
+		Until:    app.readTime(qs, "until", v, localizer),
+	}
+
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, v, localizer),
+		PageSize:     app.readInt(qs, "page_size", 50, v, localizer),
+		Sort:         "-created_at",
+		SortSafeList: []string{"-created_at"},
+	}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	events, metadata, err := app.models.Audit.GetAll(ctx, filter, filters)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{
+		"events":   events,
+		"metadata": metadata,
+	})
+}
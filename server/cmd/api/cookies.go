@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"adcentra.ai/internal/data"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	refreshTokenCookieName = "refresh_token"
+	csrfTokenCookieName    = "csrf_token"
+	csrfTokenHeader        = "X-CSRF-Token"
+)
+
+// cookieIssuer sets and clears the cookies that carry the refresh token, with the
+// configured domain, path, SameSite mode and Secure flag. An empty domain scopes the cookies
+// to the API host; naming a parent domain shares them with SPAs on its other subdomains.
+type cookieIssuer struct {
+	domain   string
+	path     string
+	secure   bool
+	sameSite http.SameSite
+}
+
+func newCookieIssuer(cfg config) (*cookieIssuer, error) {
+	var sameSite http.SameSite
+	switch strings.ToLower(cfg.cookies.sameSite) {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "lax":
+		sameSite = http.SameSiteLaxMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("invalid cookie SameSite mode %q", cfg.cookies.sameSite)
+	}
+
+	// Browsers drop SameSite=None cookies that aren't also Secure.
+	if sameSite == http.SameSiteNoneMode && !cfg.cookies.secure {
+		return nil, fmt.Errorf("SameSite=None cookies must be secure")
+	}
+
+	if !strings.HasPrefix(cfg.cookies.path, "/") {
+		return nil, fmt.Errorf("invalid cookie path %q", cfg.cookies.path)
+	}
+
+	return &cookieIssuer{
+		domain:   cfg.cookies.domain,
+		path:     cfg.cookies.path,
+		secure:   cfg.cookies.secure,
+		sameSite: sameSite,
+	}, nil
+}
+
+// setRefreshToken sets the refresh token cookie, along with the CSRF token that has to be
+// sent back in the X-CSRF-Token header to use it. The CSRF token is readable by scripts,
+// from its cookie when the SPA shares the cookie domain and from the response header
+// otherwise.
+func (ci *cookieIssuer) setRefreshToken(e echo.Context, token *data.Token) {
+	csrfToken := csrfTokenFor(token.Plaintext)
+
+	e.SetCookie(&http.Cookie{
+		Name:     refreshTokenCookieName,
+		Value:    token.Plaintext,
+		Expires:  token.Expiry.Time,
+		Domain:   ci.domain,
+		Path:     ci.path,
+		HttpOnly: true,
+		Secure:   ci.secure,
+		SameSite: ci.sameSite,
+	})
+	e.SetCookie(&http.Cookie{
+		Name:     csrfTokenCookieName,
+		Value:    csrfToken,
+		Expires:  token.Expiry.Time,
+		Domain:   ci.domain,
+		Path:     "/",
+		Secure:   ci.secure,
+		SameSite: ci.sameSite,
+	})
+	e.Response().Header().Set(csrfTokenHeader, csrfToken)
+}
+
+// clearRefreshToken removes the refresh token and CSRF token cookies. The attributes must
+// match the ones they were set with, or browsers keep them.
+func (ci *cookieIssuer) clearRefreshToken(e echo.Context) {
+	e.SetCookie(&http.Cookie{
+		Name:     refreshTokenCookieName,
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		Domain:   ci.domain,
+		Path:     ci.path,
+		HttpOnly: true,
+		Secure:   ci.secure,
+		SameSite: ci.sameSite,
+	})
+	e.SetCookie(&http.Cookie{
+		Name:     csrfTokenCookieName,
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		Domain:   ci.domain,
+		Path:     "/",
+		Secure:   ci.secure,
+		SameSite: ci.sameSite,
+	})
+}
+
+// setOAuthFlow sets the cookie that carries the state of an OAuth login to its callback. It
+// is scoped to the API host and the OAuth routes whatever the configured domain and path, and
+// SameSite must be Lax, as the callback is a top-level navigation coming from the provider.
+func (ci *cookieIssuer) setOAuthFlow(e echo.Context, value string, ttl time.Duration) {
+	e.SetCookie(&http.Cookie{
+		Name:     oauthFlowCookieName,
+		Value:    value,
+		Path:     oauthFlowCookiePath,
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   ci.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearOAuthFlow removes the OAuth login state cookie.
+func (ci *cookieIssuer) clearOAuthFlow(e echo.Context) {
+	e.SetCookie(&http.Cookie{
+		Name:     oauthFlowCookieName,
+		Path:     oauthFlowCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   ci.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// checkCSRFToken reports whether the request carries the CSRF token of its refresh token in
+// the X-CSRF-Token header. This is the double-submit pattern, with the token derived from
+// the refresh token rather than stored: another site can make the browser send the cookie,
+// but can neither read it nor set the header, and a CSRF cookie planted from a sibling
+// subdomain doesn't match the victim's refresh token.
+func checkCSRFToken(e echo.Context, refreshToken string) bool {
+	header := e.Request().Header.Get(csrfTokenHeader)
+	if header == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(header), []byte(csrfTokenFor(refreshToken))) == 1
+}
+
+// csrfTokenFor derives the CSRF token of a refresh token. The hash can't be reversed, so
+// exposing it to scripts doesn't expose the refresh token.
+func csrfTokenFor(refreshToken string) string {
+	sum := sha256.Sum256([]byte("csrf:" + refreshToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
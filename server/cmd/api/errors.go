@@ -2,8 +2,11 @@ package main
 
 import (
 	"log/slog"
+	"math"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"time"
 
 	"adcentra.ai/internal/i18n"
 	"github.com/labstack/echo/v4"
@@ -59,6 +62,12 @@ func (app *application) badRequestResponse(c echo.Context, err error) error {
 	return echo.NewHTTPError(http.StatusBadRequest, message + ": " + err.Error())
 }
 
+func (app *application) notFoundResponse(c echo.Context) error {
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "NotFound", nil)
+	return echo.NewHTTPError(http.StatusNotFound, message)
+}
+
 func (app *application) failedValidationResponse(c echo.Context, errors map[string]string) error {
 	return echo.NewHTTPError(http.StatusUnprocessableEntity, app.wrapValidationErrors(c, errors))
 }
@@ -69,12 +78,24 @@ func (app *application) editConflictResponse(c echo.Context) error {
 	return echo.NewHTTPError(http.StatusConflict, message)
 }
 
+func (app *application) preconditionFailedResponse(c echo.Context) error {
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "PreconditionFailed", nil)
+	return echo.NewHTTPError(http.StatusPreconditionFailed, message)
+}
+
 func (app *application) invalidCredentialsResponse(c echo.Context) error {
 	localizer := app.contextGetLocalizer(c)
 	message := i18n.LocalizeMessage(localizer, "InvalidCredentials", nil)
 	return echo.NewHTTPError(http.StatusUnauthorized, message)
 }
 
+func (app *application) invalidTwoFactorCodeResponse(c echo.Context) error {
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "InvalidTwoFactorCode", nil)
+	return echo.NewHTTPError(http.StatusUnauthorized, message)
+}
+
 func (app *application) invalidAuthenticationTokenResponse(c echo.Context) error {
 	c.Response().Header().Set("WWW-Authenticate", "Bearer")
 
@@ -100,3 +121,74 @@ func (app *application) notPermittedResponse(c echo.Context) error {
 	message := i18n.LocalizeMessage(localizer, "NotPermitted", nil)
 	return echo.NewHTTPError(http.StatusForbidden, message)
 }
+
+func (app *application) twoFactorAlreadyEnabledResponse(c echo.Context) error {
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "TwoFactorAlreadyEnabled", nil)
+	return echo.NewHTTPError(http.StatusConflict, message)
+}
+
+func (app *application) twoFactorNotEnabledResponse(c echo.Context) error {
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "TwoFactorNotEnabled", nil)
+	return echo.NewHTTPError(http.StatusConflict, message)
+}
+
+func (app *application) accountLockedResponse(c echo.Context, retryAfter time.Duration) error {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "AccountLocked", nil)
+	return echo.NewHTTPError(http.StatusTooManyRequests, message)
+}
+
+func (app *application) magicLinkThrottledResponse(c echo.Context, retryAfter time.Duration) error {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "MagicLinkThrottled", nil)
+	return echo.NewHTTPError(http.StatusTooManyRequests, message)
+}
+
+func (app *application) systemRoleResponse(c echo.Context) error {
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "SystemRoleCannotBeDeleted", nil)
+	return echo.NewHTTPError(http.StatusConflict, message)
+}
+
+func (app *application) roleInUseResponse(c echo.Context) error {
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "RoleInUse", nil)
+	return echo.NewHTTPError(http.StatusConflict, message)
+}
+
+func (app *application) lastOwnerResponse(c echo.Context) error {
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "LastOrganizationOwner", nil)
+	return echo.NewHTTPError(http.StatusConflict, message)
+}
+
+func (app *application) organizationRequiredResponse(c echo.Context) error {
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "OrganizationRequired", nil)
+	return echo.NewHTTPError(http.StatusBadRequest, message)
+}
+
+// reauthenticationRequiredResponse tells the client to have the user prove their identity
+// again, in the form of RFC 9470's step-up challenge.
+func (app *application) reauthenticationRequiredResponse(c echo.Context, maxAge time.Duration) error {
+	c.Response().Header().Set("WWW-Authenticate", `Bearer error="insufficient_user_authentication", max_age=`+strconv.Itoa(int(maxAge.Seconds())))
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "ReauthenticationRequired", nil)
+	return echo.NewHTTPError(http.StatusUnauthorized, message)
+}
+
+func (app *application) invalidCSRFTokenResponse(c echo.Context) error {
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "InvalidCSRFToken", nil)
+	return echo.NewHTTPError(http.StatusForbidden, message)
+}
+
+func (app *application) accountDisabledResponse(c echo.Context) error {
+	localizer := app.contextGetLocalizer(c)
+	message := i18n.LocalizeMessage(localizer, "AccountDisabled", nil)
+	return echo.NewHTTPError(http.StatusForbidden, message)
+}
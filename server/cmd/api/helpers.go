@@ -1,9 +1,20 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/validator"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
 func (app *application) background(fn func()) {
@@ -20,3 +31,96 @@ func (app *application) background(fn func()) {
 		fn()
 	})
 }
+
+func (app *application) readIDParam(e echo.Context) (int64, error) {
+	id, err := strconv.ParseInt(e.Param("id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid id parameter")
+	}
+
+	return id, nil
+}
+
+// readString returns the query string value for the key, or defaultValue if it is missing.
+func (app *application) readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	return s
+}
+
+// readInt returns the query string value for the key as an int, or defaultValue if it is
+// missing. A value that is not an integer is recorded in the validator.
+func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator, localizer *goi18n.Localizer) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddError(key, i18n.LocalizeMessage(localizer, "MustBeAnIntegerValue", nil))
+		return defaultValue
+	}
+
+	return i
+}
+
+// readBool returns the query string value for the key as a bool, or nil if it is missing.
+// A value that is not a boolean is recorded in the validator.
+func (app *application) readBool(qs url.Values, key string, v *validator.Validator, localizer *goi18n.Localizer) *bool {
+	s := qs.Get(key)
+	if s == "" {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, i18n.LocalizeMessage(localizer, "MustBeABooleanValue", nil))
+		return nil
+	}
+
+	return &b
+}
+
+// readTime returns the query string value for the key as an RFC 3339 timestamp, or an
+// invalid timestamp if it is missing. A malformed value is recorded in the validator.
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator, localizer *goi18n.Localizer) pgtype.Timestamptz {
+	s := qs.Get(key)
+	if s == "" {
+		return pgtype.Timestamptz{}
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		v.AddError(key, i18n.LocalizeMessage(localizer, "MustBeATimestamp", nil))
+		return pgtype.Timestamptz{}
+	}
+
+	return pgtype.Timestamptz{Time: t, Valid: true}
+}
+
+// versionETag returns the entity tag of a resource with an optimistic locking version.
+func versionETag(version int32) string {
+	return strconv.Quote(strconv.FormatInt(int64(version), 10))
+}
+
+// ifMatch reports whether the If-Match header of the request, if any, matches the
+// entity tag. Weak tags are compared by their opaque value.
+func ifMatch(e echo.Context, etag string) bool {
+	header := e.Request().Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+
+	for tag := range strings.SplitSeq(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
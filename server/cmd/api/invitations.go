@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// createInvitation invites an email address to the active organization. The inviter can
+// only hand out roles they could grant themselves.
+func (app *application) createInvitation(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 6*time.Second)
+	defer cancel()
+
+	var input struct {
+		Email    string `json:"email"`
+		Role     string `json:"role"`
+		Language string `json:"language"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	v := validator.New()
+
+	data.ValidateEmail(v, localizer, input.Email)
+	v.Check(validator.NotBlank(input.Role), "role", i18n.LocalizeMessage(localizer, "RoleMustBeProvided", nil))
+
+	if !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	role := data.Role(input.Role)
+
+	err := app.models.Roles.Exists(ctx, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("role", i18n.LocalizeMessage(localizer, "RoleNotFound", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	err = app.requireGrantableOrgRole(ctx, e, role)
+	if err != nil {
+		return err
+	}
+
+	session := app.contextGetSession(e)
+
+	org, err := app.models.Organizations.Get(ctx, session.OrganizationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	inviter, err := app.currentUser(ctx, e)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	invitation, err := app.models.Invitations.New(ctx, org.ID, inviter.ID, input.Email, role, invitationTokenTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	// The email goes out in the language the inviter picked for the invitee, or their own.
+	language := input.Language
+	if language == "" {
+		language = e.Request().Header.Get("Accept-Language")
+	}
+	language = i18n.GetLanguageFromAcceptLanguage(language)
+
+	app.background(func() {
+		data := map[string]any{
+			"inviterName":      inviter.FullName,
+			"organizationName": org.Name,
+			"role":             string(role),
+			"invitationURL":    app.config.invitations.url + "#token=" + invitation.Plaintext,
+			"invitationTTL":    int(invitationTokenTTL.Hours() / 24),
+		}
+
+		err := app.mailer.SendLocalized(input.Email, language, "organization_invitation.tmpl", data)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	return e.JSON(http.StatusAccepted, echo.Map{
+		"invitation": invitation,
+		"message":    i18n.LocalizeMessage(localizer, "InvitationCreated", nil),
+	})
+}
+
+func (app *application) listInvitations(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	session := app.contextGetSession(e)
+
+	invitations, err := app.models.Invitations.GetAllForOrganization(ctx, session.OrganizationID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{"invitations": invitations})
+}
+
+// deleteInvitation revokes a pending invitation so that its link stops working.
+func (app *application) deleteInvitation(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	id, err := app.readIDParam(e)
+	if err != nil {
+		return app.notFoundResponse(e)
+	}
+
+	session := app.contextGetSession(e)
+
+	err = app.models.Invitations.Delete(ctx, id, session.OrganizationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	return e.NoContent(http.StatusOK)
+}
+
+// acceptInvitation adds the invitee to the organization they were invited to. Signed in
+// users join with their account, which must belong to the invited email address so that a
+// leaked link is useless to anyone else. Anyone else registers with the invited email
+// address; since the invitation proves they own it, the account is activated right away.
+func (app *application) acceptInvitation(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var input struct {
+		TokenPlainText string `json:"token"`
+		FullName       string `json:"full_name"`
+		Password       string `json:"password"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, localizer, input.TokenPlainText); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	invitation, err := app.models.Invitations.GetForToken(ctx, input.TokenPlainText)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", i18n.LocalizeMessage(localizer, "InvalidToken", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	grantable, err := app.invitationGrantable(ctx, invitation)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	if !grantable {
+		v.AddError("token", i18n.LocalizeMessage(localizer, "InvalidToken", nil))
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	session := app.contextGetSession(e)
+	if session.User.IsAnonymous() {
+		return app.registerInvitedUser(ctx, e, invitation, input.FullName, input.Password)
+	}
+
+	user, err := app.currentUser(ctx, e)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		v.AddError("token", i18n.LocalizeMessage(localizer, "InvitationEmailMismatch", nil))
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	err = app.models.Invitations.Accept(ctx, invitation, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrAlreadyMember):
+			v.AddError("token", i18n.LocalizeMessage(localizer, "AlreadyOrganizationMember", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", i18n.LocalizeMessage(localizer, "InvalidToken", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	if !user.Activated {
+		user.Activated = true
+
+		err = app.models.Users.Update(ctx, user)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrEditConflict):
+				return app.editConflictResponse(e)
+			default:
+				return app.serverErrorResponse(e, err)
+			}
+		}
+	}
+
+	org, err := app.models.Organizations.Get(ctx, invitation.OrganizationID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+	org.Role = invitation.Role
+
+	return e.JSON(http.StatusOK, echo.Map{"organization": org})
+}
+
+// invitationGrantable reports whether the inviter could still grant the invited role, as
+// they had to when inviting: their account must still be usable, and they must be a member
+// of the organization with a role allowing it, or a superadmin. Invitations lapse when the
+// inviter leaves or is demoted.
+func (app *application) invitationGrantable(ctx context.Context, invitation *data.Invitation) (bool, error) {
+	inviterID := invitation.InvitedBy
+
+	status, err := app.models.Users.GetStatus(ctx, inviterID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	if !status.Active() {
+		return false, nil
+	}
+
+	roles, err := app.models.Roles.GetAllForUser(ctx, inviterID)
+	if err != nil {
+		return false, err
+	}
+
+	if roles.Has(data.RoleSuperAdmin) {
+		return true, nil
+	}
+
+	membership, err := app.models.Organizations.GetMembership(ctx, invitation.OrganizationID, inviterID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	rolePermissions, err := app.models.Permissions.GetAllForRoleCode(ctx, invitation.Role)
+	if err != nil {
+		return false, err
+	}
+
+	return data.CanGrantRole(roles, membership.Permissions, invitation.Role, rolePermissions), nil
+}
+
+// registerInvitedUser registers an account for the invited email address the same way
+// registerUser does, except that it is activated and joins the organization right away,
+// and logs the new user in. Invitees who already have an account must log in first.
+func (app *application) registerInvitedUser(ctx context.Context, e echo.Context, invitation *data.Invitation, fullName, password string) error {
+	localizer := app.contextGetLocalizer(e)
+
+	_, err := app.models.Users.GetByEmail(ctx, invitation.Email)
+	switch {
+	case err == nil:
+		return app.authenticationRequiredResponse(e)
+	case !errors.Is(err, data.ErrRecordNotFound):
+		return app.serverErrorResponse(e, err)
+	}
+
+	user := &data.User{
+		FullName:  fullName,
+		Email:     invitation.Email,
+		Activated: true,
+	}
+
+	if err := app.models.Users.SetPassword(user, password); err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	v := validator.New()
+
+	if data.ValidateUser(v, localizer, user); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	if app.validatePasswordNotBreached(ctx, v, localizer, password); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	err = app.models.Invitations.AcceptForNewUser(ctx, invitation, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			return app.authenticationRequiredResponse(e)
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", i18n.LocalizeMessage(localizer, "InvalidToken", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	app.audit(ctx, e, data.AuditUserRegistered, user.ID, user.ID, map[string]any{
+		"organization_id": invitation.OrganizationID,
+		"role":            invitation.Role,
+	})
+
+	return app.completeLogin(ctx, e, user, "")
+}
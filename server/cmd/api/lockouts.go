@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/policy"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	failedLoginBaseDelay = 250 * time.Millisecond
+	failedLoginMaxDelay  = 4 * time.Second
+)
+
+func (app *application) lockoutPolicy() data.LockoutPolicy {
+	return data.LockoutPolicy{
+		MaxAttempts:     app.config.lockout.maxAttempts,
+		LockoutDuration: app.config.lockout.duration,
+		Window:          app.config.lockout.window,
+	}
+}
+
+// failedLoginDelay grows exponentially with the number of recent failures, so
+// guessing passwords for a single email gets slower with every attempt.
+func failedLoginDelay(failedCount int) time.Duration {
+	delay := failedLoginBaseDelay
+	for i := 1; i < failedCount && delay < failedLoginMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, failedLoginMaxDelay)
+}
+
+// failedLoginResponse records a failed login for the email and delays the response. Failures
+// are counted for unknown emails too, so lockouts don't reveal whether an account exists.
+func (app *application) failedLoginResponse(ctx context.Context, e echo.Context, email string, user *data.User) error {
+	attempts, locked, err := app.models.Logins.RecordFailure(ctx, email, app.lockoutPolicy())
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.audit(ctx, e, data.AuditLoginFailed, 0, user.ID, map[string]any{
+		"reason": "password",
+		"email":  email,
+		"locked": locked,
+	})
+
+	if locked {
+		app.logger.Warn("account locked after failed logins", "email", email, "failed_count", attempts.FailedCount, "ip", e.RealIP())
+
+		// Anyone can lock an account by failing to log in, so the notice is throttled like
+		// other security notifications.
+		if user.ID != 0 {
+			app.notify(e, user, "account_locked.tmpl", map[string]any{
+				"lockedUntil": attempts.LockedUntil.UTC().Format(time.RFC1123),
+			})
+		}
+	}
+
+	timer := time.NewTimer(failedLoginDelay(attempts.FailedCount))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+
+	if locked {
+		return app.accountLockedResponse(e, attempts.RetryAfter())
+	}
+	return app.invalidCredentialsResponse(e)
+}
+
+// verifyCurrentPassword checks the password a signed-in user gives to confirm a sensitive
+// operation. Failures count towards the lockout just like failed logins, so a stolen token
+// can't be used to guess the password. On failure the returned error is the response to send.
+func (app *application) verifyCurrentPassword(ctx context.Context, e echo.Context, user *data.User, password string) error {
+	attempts, err := app.models.Logins.Get(ctx, user.Email)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	if attempts.Locked() {
+		return app.accountLockedResponse(e, attempts.RetryAfter())
+	}
+
+	match, err := app.models.Users.PasswordMatches(user, password)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	if !match {
+		return app.failedLoginResponse(ctx, e, user.Email, user)
+	}
+
+	if attempts.FailedCount > 0 {
+		err = app.models.Logins.Reset(ctx, user.Email)
+		if err != nil {
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	return nil
+}
+
+func (app *application) clearUserLockout(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	// The lockout guards every way into the account, like its password.
+	user, err := app.readUserParam(ctx, e, policy.ManageUserAccount)
+	if err != nil {
+		return err
+	}
+
+	err = app.models.Logins.Reset(ctx, user.Email)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.audit(ctx, e, data.AuditLockoutCleared, app.contextGetSession(e).UserID, user.ID, nil)
+
+	return e.JSON(http.StatusOK, echo.Map{"message": i18n.LocalizeMessage(localizer, "LockoutCleared", nil)})
+}
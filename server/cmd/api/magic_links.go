@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"adcentra.ai/internal/cache"
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// Sign-in link requests are throttled per email, so that they can't be used to flood an
+// inbox: at most magicLinkMaxRequests links are sent within magicLinkWindow.
+const (
+	magicLinkMaxRequests = 3
+	magicLinkWindow      = time.Hour
+)
+
+// magicLinkRequests counts the link requests for an email since the window started.
+type magicLinkRequests struct {
+	Count       int       `json:"count"`
+	WindowStart time.Time `json:"window_start"`
+}
+
+// throttleMagicLink counts a link request for the email, and returns how long to wait
+// before another one is sent if too many were requested within the window, or 0. Requests
+// are counted in the cache, which forgets them when the window ends; without a cache, only
+// the IP rate limiter applies.
+func (app *application) throttleMagicLink(ctx context.Context, email string) time.Duration {
+	key := cache.GenerateMagicLinkRequestsKey(email)
+	now := time.Now()
+
+	var requests magicLinkRequests
+	if !cache.TryGet(ctx, app.cache, key, &requests) || now.Sub(requests.WindowStart) >= magicLinkWindow {
+		requests = magicLinkRequests{WindowStart: now}
+	}
+
+	windowEnd := requests.WindowStart.Add(magicLinkWindow)
+	if requests.Count >= magicLinkMaxRequests {
+		return windowEnd.Sub(now)
+	}
+
+	requests.Count++
+	cache.TrySet(ctx, app.cache, key, requests, windowEnd.Sub(now))
+	return 0
+}
+
+func (app *application) createMagicLinkToken(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 6*time.Second)
+	defer cancel()
+
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	v := validator.New()
+
+	if data.ValidateEmail(v, localizer, input.Email); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	// Unlike password resets, this is a login endpoint, so the response is the same whether
+	// or not the email belongs to an account.
+	message := i18n.LocalizeMessage(localizer, "MagicLinkCreated", nil)
+
+	// Requests are counted for unknown emails too, so that being throttled doesn't reveal
+	// whether an account exists either.
+	if retryAfter := app.throttleMagicLink(ctx, input.Email); retryAfter > 0 {
+		return app.magicLinkThrottledResponse(e, retryAfter)
+	}
+
+	user, err := app.models.Users.GetByEmail(ctx, input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return e.JSON(http.StatusAccepted, echo.Map{"message": message})
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	token, err := app.models.Tokens.New(ctx, user.ID, magicLinkTokenTTL, data.ScopeMagicLink)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.background(func() {
+		data := map[string]any{
+			"fullName":     user.FullName,
+			"magicLinkURL": app.config.magicLink.url + "#token=" + token.Plaintext,
+			"magicLinkTTL": int(magicLinkTokenTTL.Minutes()),
+		}
+
+		err := app.mailer.Send(user.Email, "magic_link.tmpl", data)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	return e.JSON(http.StatusAccepted, echo.Map{"message": message})
+}
+
+func (app *application) createAuthenticationTokenFromMagicLink(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var input struct {
+		TokenPlainText string `json:"token"`
+		DeviceName     string `json:"device_name"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	v := validator.New()
+
+	data.ValidateTokenPlaintext(v, localizer, input.TokenPlainText)
+	data.ValidateDeviceName(v, localizer, input.DeviceName)
+
+	if !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	user, err := app.models.Users.GetForToken(ctx, data.ScopeMagicLink, input.TokenPlainText)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.invalidCredentialsResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	// Passwordless logins are held to the lockout too, as it means the account is under
+	// attack. The link stays valid in the meantime.
+	attempts, err := app.models.Logins.Get(ctx, user.Email)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	if attempts.Locked() {
+		return app.accountLockedResponse(e, attempts.RetryAfter())
+	}
+
+	if user.Disabled {
+		return app.accountDisabledResponse(e)
+	}
+
+	// Links are single use: consume every outstanding link for the user before logging in.
+	err = app.models.Tokens.DeleteAllForUser(ctx, data.ScopeMagicLink, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	// Following the link proves ownership of the email, same as an activation token. But
+	// until now anyone could have registered the address, so the account is claimed for its
+	// owner, discarding the password and sessions that person may have set up.
+	if !user.Activated {
+		err = app.models.Users.Claim(ctx, user)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrEditConflict):
+				return app.editConflictResponse(e)
+			default:
+				return app.serverErrorResponse(e, err)
+			}
+		}
+	}
+
+	return app.beginLogin(ctx, e, user, input.DeviceName)
+}
@@ -12,10 +12,15 @@ import (
 	"sync"
 	"time"
 
+	"adcentra.ai/internal/accesstoken"
 	"adcentra.ai/internal/cache"
 	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/hasher"
 	"adcentra.ai/internal/i18n"
 	"adcentra.ai/internal/mailer"
+	"adcentra.ai/internal/oauth"
+	"adcentra.ai/internal/policy"
+	"adcentra.ai/internal/validator"
 	"adcentra.ai/internal/vcs"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
@@ -54,20 +59,81 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	cookies struct {
+		domain   string
+		path     string
+		sameSite string
+		secure   bool
+	}
+	lockout struct {
+		maxAttempts int
+		duration    time.Duration
+		window      time.Duration
+	}
 	cleanup struct {
-		tokensCleanupPeriod time.Duration
+		tokensCleanupPeriod     time.Duration
+		deletedUsersPurgePeriod time.Duration
+	}
+	accountDeletion struct {
+		gracePeriod time.Duration
+	}
+	magicLink struct {
+		url string
+	}
+	invitations struct {
+		url string
+	}
+	notifications struct {
+		interval time.Duration
+	}
+	reauthentication struct {
+		maxAge time.Duration
+	}
+	passwords struct {
+		breachSource  string
+		hashAlgorithm string
+		argon2id      struct {
+			memory      uint
+			iterations  uint
+			parallelism uint
+		}
+		bcryptCost int
+	}
+	accessTokens struct {
+		signingKey       string
+		verificationKeys []string
+	}
+	oauth struct {
+		redirectBaseURL     string
+		frontendCallbackURL string
+		google              struct {
+			clientID     string
+			clientSecret string
+			issuer       string
+		}
+		microsoft struct {
+			clientID     string
+			clientSecret string
+			tenant       string
+		}
 	}
 }
 
 type application struct {
-	config          config
-	logger          *slog.Logger
-	models          data.Models
-	cache           cache.Cache
-	mailer          *mailer.Mailer
-	wg              sync.WaitGroup
-	serverCtx       context.Context
-	serverCtxCancel context.CancelFunc
+	config            config
+	logger            *slog.Logger
+	models            data.Models
+	cache             cache.Cache
+	mailer            *mailer.Mailer
+	oauthProviders    map[string]*oauth.Provider
+	accessTokens      *accesstoken.KeySet
+	policy            *policy.Policy
+	notifications     *notificationThrottle
+	cookies           *cookieIssuer
+	breachedPasswords validator.BreachedPasswords
+	wg                sync.WaitGroup
+	serverCtx         context.Context
+	serverCtxCancel   context.CancelFunc
 }
 
 func main() {
@@ -95,6 +161,10 @@ func main() {
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 8, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
 
+	flag.IntVar(&cfg.lockout.maxAttempts, "lockout-max-attempts", 5, "Failed logins per email before the account is temporarily locked")
+	flag.DurationVar(&cfg.lockout.duration, "lockout-duration", 15*time.Minute, "Initial account lockout duration, doubled on each further lockout (default: 15m)")
+	flag.DurationVar(&cfg.lockout.window, "lockout-window", time.Hour, "Period after which failed logins are forgotten (default: 1h)")
+
 	// Create a new version boolean flag with the default value of false.
 	displayVersion := flag.Bool("version", false, "Display version and exit")
 
@@ -102,8 +172,41 @@ func main() {
 		cfg.cors.trustedOrigins = strings.Fields(val)
 		return nil
 	})
+	flag.StringVar(&cfg.cookies.domain, "cookie-domain", "", "Domain of the refresh token cookies, e.g. a parent domain shared with the frontend; the API host if empty")
+	flag.StringVar(&cfg.cookies.path, "cookie-path", "/v1/", "Path of the refresh token cookie")
+	flag.StringVar(&cfg.cookies.sameSite, "cookie-same-site", "strict", "SameSite mode of the refresh token cookies (strict|lax|none)")
+	flag.BoolVar(&cfg.cookies.secure, "cookie-secure", true, "Only send the refresh token cookies over HTTPS; disable for local development over HTTP")
 
 	flag.DurationVar(&cfg.cleanup.tokensCleanupPeriod, "tokens-cleanup-period", time.Hour*12, "Tokens cleanup period (default: 12h)")
+	flag.DurationVar(&cfg.cleanup.deletedUsersPurgePeriod, "deleted-users-purge-period", time.Hour, "Period of the job that permanently deletes accounts past their grace period (default: 1h)")
+	flag.DurationVar(&cfg.accountDeletion.gracePeriod, "account-deletion-grace-period", 30*24*time.Hour, "Time after a deletion request during which logging in cancels the deletion (default: 720h)")
+
+	flag.StringVar(&cfg.magicLink.url, "magic-link-url", "http://localhost:5173/auth/magic-link", "Frontend URL that magic login links point to; the token is appended as a URL fragment")
+	flag.StringVar(&cfg.invitations.url, "invitation-url", "http://localhost:5173/invitations/accept", "Frontend URL that invitation links point to; the token is appended as a URL fragment")
+	flag.StringVar(&cfg.passwords.hashAlgorithm, "password-hash-algorithm", "argon2id", "Algorithm new password hashes are made with (argon2id|bcrypt); hashes made with the other one are replaced on login")
+	flag.UintVar(&cfg.passwords.argon2id.memory, "argon2id-memory", uint(hasher.DefaultArgon2id.Memory), "Memory used by argon2id, in KiB")
+	flag.UintVar(&cfg.passwords.argon2id.iterations, "argon2id-iterations", uint(hasher.DefaultArgon2id.Iterations), "Number of argon2id iterations")
+	flag.UintVar(&cfg.passwords.argon2id.parallelism, "argon2id-parallelism", uint(hasher.DefaultArgon2id.Parallelism), "Number of argon2id threads")
+	flag.IntVar(&cfg.passwords.bcryptCost, "bcrypt-cost", hasher.DefaultBcrypt.Cost, "bcrypt cost")
+	flag.StringVar(&cfg.passwords.breachSource, "password-breach-source", "", "Where breached passwords are looked up: hibp for the Pwned Passwords API, or a file of SHA-1 hashes (HASH:COUNT per line); not checked if empty")
+	flag.DurationVar(&cfg.notifications.interval, "notification-interval", 15*time.Minute, "Minimum time between two security notifications of the same kind to a user (default: 15m)")
+	flag.DurationVar(&cfg.reauthentication.maxAge, "reauthentication-max-age", 15*time.Minute, "How long after proving their password or second factor users may perform sensitive operations (default: 15m)")
+
+	flag.StringVar(&cfg.accessTokens.signingKey, "access-token-signing-key", os.Getenv("ACCESS_TOKEN_SIGNING_KEY"), "Key (kid:seed) used to issue signed access tokens; opaque access tokens are issued if empty")
+	flag.Func("access-token-verification-keys", "Additional keys (kid:public key) accepted for signed access tokens, e.g. retired signing keys (space separated within double quotes)", func(val string) error {
+		cfg.accessTokens.verificationKeys = strings.Fields(val)
+		return nil
+	})
+	generateAccessTokenKey := flag.String("generate-access-token-key", "", "Print a new access token signing key with the given kid and exit")
+
+	flag.StringVar(&cfg.oauth.redirectBaseURL, "oauth-redirect-base-url", "http://localhost:4000", "Public base URL of the API used to build OAuth callback URLs")
+	flag.StringVar(&cfg.oauth.frontendCallbackURL, "oauth-frontend-callback-url", "http://localhost:5173/auth/oauth/callback", "Frontend URL the user is sent to after an OAuth login")
+	flag.StringVar(&cfg.oauth.google.clientID, "oauth-google-client-id", os.Getenv("OAUTH_GOOGLE_CLIENT_ID"), "Google OAuth client ID (Google login is disabled if empty)")
+	flag.StringVar(&cfg.oauth.google.clientSecret, "oauth-google-client-secret", os.Getenv("OAUTH_GOOGLE_CLIENT_SECRET"), "Google OAuth client secret")
+	flag.StringVar(&cfg.oauth.google.issuer, "oauth-google-issuer", "https://accounts.google.com", "Google OpenID Connect issuer URL (can point to a local stub provider)")
+	flag.StringVar(&cfg.oauth.microsoft.clientID, "oauth-microsoft-client-id", os.Getenv("OAUTH_MICROSOFT_CLIENT_ID"), "Microsoft OAuth client ID (Microsoft login is disabled if empty)")
+	flag.StringVar(&cfg.oauth.microsoft.clientSecret, "oauth-microsoft-client-secret", os.Getenv("OAUTH_MICROSOFT_CLIENT_SECRET"), "Microsoft OAuth client secret")
+	flag.StringVar(&cfg.oauth.microsoft.tenant, "oauth-microsoft-tenant", "common", "Microsoft Entra tenant (common|organizations|consumers|<tenant id>)")
 
 	flag.Parse()
 
@@ -114,6 +217,13 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *generateAccessTokenKey != "" {
+		key := accesstoken.GenerateKey(*generateAccessTokenKey)
+		fmt.Printf("Signing key:\t%s\n", accesstoken.EncodeSigningKey(key))
+		fmt.Printf("Verification key:\t%s\n", accesstoken.EncodeVerificationKey(key))
+		os.Exit(0)
+	}
+
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
 	pool, err := intiDB(cfg)
@@ -154,6 +264,30 @@ func main() {
 		return time.Now().Unix()
 	}))
 
+	accessTokens, err := newAccessTokenKeySet(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	breachedPasswords, err := newBreachedPasswords(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	cookies, err := newCookieIssuer(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	passwordHasher, err := newPasswordHasher(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
 	mailer, err := mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender)
 	if err != nil {
 		logger.Error(err.Error())
@@ -180,14 +314,25 @@ func main() {
 	app := &application{
 		config: cfg,
 		logger: logger,
-		models: data.NewModels(pool, cacheInstance),
+		models: data.NewModels(pool, cacheInstance, passwordHasher),
 		cache:  cacheInstance,
 		mailer: mailer,
+
+		oauthProviders: newOAuthProviders(cfg),
+		accessTokens:   accessTokens,
+		policy:         policy.Default(),
+		notifications:  newNotificationThrottle(cfg.notifications.interval),
+		cookies:        cookies,
+
+		breachedPasswords: breachedPasswords,
 	}
 
 	// Create a new context for background goroutines which is cancelled on graceful shutdown.
 	app.serverCtx, app.serverCtxCancel = context.WithCancel(context.Background())
 
+	app.background(app.CleanupTokens)
+	app.background(app.PurgeDeletedUsers)
+
 	err = app.serve()
 	if err != nil {
 		logger.Error(err.Error())
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"adcentra.ai/internal/accesstoken"
 	"adcentra.ai/internal/data"
 	"adcentra.ai/internal/i18n"
 	"adcentra.ai/internal/validator"
@@ -73,6 +74,47 @@ func (app *application) authenticate(next echo.HandlerFunc) echo.HandlerFunc {
 
 		tokenPlainText := headerParts[1]
 
+		if app.accessTokens != nil && accesstoken.IsAccessToken(tokenPlainText) {
+			session, err := app.sessionFromAccessToken(tokenPlainText)
+			if err != nil {
+				return app.invalidAuthenticationTokenResponse(c)
+			}
+
+			// The claims can't tell whether the account has been closed or disabled since the
+			// token was issued, so that is looked up, through the cache.
+			status, err := app.models.Users.GetStatus(ctx, session.UserID)
+			if err != nil {
+				switch {
+				case errors.Is(err, data.ErrRecordNotFound):
+					return app.invalidAuthenticationTokenResponse(c)
+				default:
+					return app.serverErrorResponse(c, err)
+				}
+			}
+
+			if !status.Active() {
+				return app.invalidAuthenticationTokenResponse(c)
+			}
+
+			app.contextSetSession(c, session)
+			return next(c)
+		}
+
+		if data.IsAPIKey(tokenPlainText) {
+			session, err := app.sessionFromAPIKey(ctx, tokenPlainText)
+			if err != nil {
+				switch {
+				case errors.Is(err, data.ErrRecordNotFound):
+					return app.invalidAuthenticationTokenResponse(c)
+				default:
+					return app.serverErrorResponse(c, err)
+				}
+			}
+
+			app.contextSetSession(c, session)
+			return next(c)
+		}
+
 		v := validator.New()
 
 		if data.ValidateTokenPlaintext(v, localizer, tokenPlainText); !v.Valid() {
@@ -89,31 +131,19 @@ func (app *application) authenticate(next echo.HandlerFunc) echo.HandlerFunc {
 			}
 		}
 
-		permissions := app.models.Permissions.GetAllForUserCached(ctx, session.User.ID)
-		if permissions == nil {
-			permissions, err = app.models.Permissions.GetAllForUser(ctx, session.User.ID)
-			if err != nil {
-				return app.serverErrorResponse(c, err)
-			}
-			app.models.Permissions.SetAllForUserToCache(ctx, session.User.ID, permissions)
+		if !session.User.Status().Active() {
+			return app.invalidAuthenticationTokenResponse(c)
+		}
+
+		permissions, roles, err := app.userPermissionsAndRoles(ctx, session.User.ID)
+		if err != nil {
+			return app.serverErrorResponse(c, err)
 		}
 		permissionMap := make(map[data.PermCode]bool)
 		for _, permission := range permissions {
 			permissionMap[permission] = true
 		}
 		session.PermissionMap = permissionMap
-
-		roles := app.models.Roles.GetAllForUserCached(ctx, session.User.ID)
-		if roles == nil {
-			roles, err = app.models.Roles.GetAllForUser(ctx, session.User.ID)
-			if err != nil {
-				return app.serverErrorResponse(c, err)
-			}
-			app.models.Roles.SetAllForUserToCache(ctx, session.User.ID, roles)
-		}
-		if err != nil {
-			return app.serverErrorResponse(c, err)
-		}
 		session.Roles = roles
 
 		app.contextSetSession(c, session)
@@ -132,6 +162,47 @@ func (app *application) requireAuthentication(next echo.HandlerFunc) echo.Handle
 	}
 }
 
+// requireRecentAuth checks that the user proved their password or second factor in the
+// current session within maxAge, so that a stolen access token alone isn't enough for
+// sensitive operations. Otherwise they have to reauthenticate first. API keys never
+// qualify, as there is nobody to prove anything.
+func (app *application) requireRecentAuth(maxAge time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+			defer cancel()
+
+			session := app.contextGetSession(c)
+
+			if session.APIKey != nil {
+				return app.notPermittedResponse(c)
+			}
+
+			// Tokens from before device sessions existed have no authentication time to go by,
+			// so their users have to log in again.
+			if session.ID == 0 {
+				return app.invalidAuthenticationTokenResponse(c)
+			}
+
+			authenticatedAt, err := app.models.Sessions.GetAuthenticatedAt(ctx, session.ID, session.UserID)
+			if err != nil {
+				switch {
+				case errors.Is(err, data.ErrRecordNotFound):
+					return app.invalidAuthenticationTokenResponse(c)
+				default:
+					return app.serverErrorResponse(c, err)
+				}
+			}
+
+			if time.Since(authenticatedAt) > maxAge {
+				return app.reauthenticationRequiredResponse(c, maxAge)
+			}
+
+			return next(c)
+		}
+	}
+}
+
 func (app *application) requireActivation(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		session := app.contextGetSession(c)
@@ -154,7 +225,63 @@ func (app *application) requirePermissions(permCodes ...data.PermCode) echo.Midd
 			}
 
 			for _, code := range permCodes {
-				if !session.PermissionMap[code] {
+				if !session.HasPermission(code) {
+					return app.notPermittedResponse(c)
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// requireOrgPermissions checks permissions in the active organization, named by the
+// X-Organization-ID header, against the user's role there rather than their global roles.
+// The membership is carried in the session for the handler. Superadmins act in any
+// organization, member or not.
+func (app *application) requireOrgPermissions(permCodes ...data.PermCode) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+			defer cancel()
+
+			session := app.contextGetSession(c)
+
+			orgID, err := strconv.ParseInt(c.Request().Header.Get(organizationHeader), 10, 64)
+			if err != nil || orgID < 1 {
+				return app.organizationRequiredResponse(c)
+			}
+
+			membership, err := app.models.Organizations.GetMembership(ctx, orgID, session.UserID)
+			if err != nil {
+				switch {
+				case errors.Is(err, data.ErrRecordNotFound) && session.Roles.Has(data.RoleSuperAdmin):
+					_, err = app.models.Organizations.Get(ctx, orgID)
+					if err != nil {
+						switch {
+						case errors.Is(err, data.ErrRecordNotFound):
+							return app.notFoundResponse(c)
+						default:
+							return app.serverErrorResponse(c, err)
+						}
+					}
+				case errors.Is(err, data.ErrRecordNotFound):
+					return app.notPermittedResponse(c)
+				default:
+					return app.serverErrorResponse(c, err)
+				}
+			}
+
+			session.OrganizationID = orgID
+			session.Membership = membership
+
+			if session.Roles.Has(data.RoleSuperAdmin) {
+				return next(c)
+			}
+
+			permissions := app.orgPermissions(session)
+			for _, code := range permCodes {
+				if !permissions.Has(code) {
 					return app.notPermittedResponse(c)
 				}
 			}
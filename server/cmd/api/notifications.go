@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/i18n"
+	"github.com/labstack/echo/v4"
+)
+
+// notificationThrottle remembers when each notification was last sent, so that a burst of
+// events sends a single email. It is kept in memory, like the request rate limiter.
+type notificationThrottle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	sent     map[string]time.Time
+}
+
+func newNotificationThrottle(interval time.Duration) *notificationThrottle {
+	return &notificationThrottle{
+		interval: interval,
+		sent:     make(map[string]time.Time),
+	}
+}
+
+// allow reports whether the notification with the key may be sent now, and if so records
+// it as sent. Entries older than the interval are forgotten along the way.
+func (t *notificationThrottle) allow(key string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for k, sentAt := range t.sent {
+		if now.Sub(sentAt) >= t.interval {
+			delete(t.sent, k)
+		}
+	}
+
+	if _, ok := t.sent[key]; ok {
+		return false
+	}
+
+	t.sent[key] = now
+	return true
+}
+
+// notify sends the user a security notification about something that happened to their
+// account. The same notification is sent at most once per throttle interval, so a burst of
+// events doesn't flood the user's inbox.
+func (app *application) notify(e echo.Context, user *data.User, templateFile string, data map[string]any) {
+	if !app.notifications.allow(fmt.Sprintf("%d:%s", user.ID, templateFile), time.Now()) {
+		return
+	}
+
+	app.sendSecurityEmail(e, user, templateFile, data)
+}
+
+// sendSecurityEmail sends the user an email about their account in the background, without
+// throttling it. The email is written in the language of the request when the user made it
+// themselves, and in the default one when someone else, such as an administrator, did. The
+// template data always includes the user's full name and the IP, device and time of the
+// request; a device given in the data is kept.
+func (app *application) sendSecurityEmail(e echo.Context, user *data.User, templateFile string, data map[string]any) {
+	language := ""
+	if session := app.contextGetSession(e); session.User.IsAnonymous() || session.UserID == user.ID {
+		language = i18n.GetLanguageFromAcceptLanguage(e.Request().Header.Get("Accept-Language"))
+	}
+
+	if data == nil {
+		data = map[string]any{}
+	}
+	data["fullName"] = user.FullName
+	data["ip"] = e.RealIP()
+	if _, ok := data["device"]; !ok {
+		data["device"] = deviceNameFromUserAgent(e.Request().UserAgent())
+	}
+	data["time"] = time.Now().UTC().Format(time.RFC1123)
+
+	recipient := user.Email
+
+	app.background(func() {
+		err := app.mailer.SendLocalized(recipient, language, templateFile, data)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+}
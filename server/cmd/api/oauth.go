@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/oauth"
+	"adcentra.ai/internal/validator"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	oauthFlowCookieName = "oauth_flow"
+	oauthFlowCookiePath = "/v1/oauth/"
+	oauthFlowTTL        = 10 * time.Minute
+)
+
+// Error codes passed to the frontend when an OAuth login can't be completed.
+const (
+	oauthErrorAccessDenied    = "access_denied"
+	oauthErrorInvalidState    = "invalid_state"
+	oauthErrorExchangeFailed  = "exchange_failed"
+	oauthErrorEmailRequired   = "email_required"
+	oauthErrorAccountExists   = "account_exists"
+	oauthErrorAccountLocked   = "account_locked"
+	oauthErrorAccountDisabled = "account_disabled"
+)
+
+var errOAuthEmailRequired = errors.New("oauth identity has no usable email address")
+
+// oauthFlow is the per-login state kept in a short-lived cookie between the start and the
+// callback of an OAuth login.
+type oauthFlow struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+func newOAuthProviders(cfg config) map[string]*oauth.Provider {
+	providers := make(map[string]*oauth.Provider)
+
+	callbackURL := func(name string) string {
+		return strings.TrimSuffix(cfg.oauth.redirectBaseURL, "/") + "/v1/oauth/" + name + "/callback"
+	}
+
+	if cfg.oauth.google.clientID != "" {
+		providers["google"] = oauth.NewProvider(oauth.Config{
+			Name:         "google",
+			IssuerURL:    cfg.oauth.google.issuer,
+			ClientID:     cfg.oauth.google.clientID,
+			ClientSecret: cfg.oauth.google.clientSecret,
+			RedirectURL:  callbackURL("google"),
+		})
+	}
+
+	if cfg.oauth.microsoft.clientID != "" {
+		tenant := cfg.oauth.microsoft.tenant
+		providers["microsoft"] = oauth.NewProvider(oauth.Config{
+			Name:            "microsoft",
+			IssuerURL:       "https://login.microsoftonline.com/" + tenant + "/v2.0",
+			ClientID:        cfg.oauth.microsoft.clientID,
+			ClientSecret:    cfg.oauth.microsoft.clientSecret,
+			RedirectURL:     callbackURL("microsoft"),
+			SkipIssuerCheck: validator.PermittedValue(tenant, "common", "organizations", "consumers"),
+		})
+	}
+
+	return providers
+}
+
+func (app *application) startOAuthLogin(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	provider, ok := app.oauthProviders[e.Param("provider")]
+	if !ok {
+		return app.notFoundResponse(e)
+	}
+
+	flow := oauthFlow{
+		Provider: provider.Name(),
+		State:    rand.Text(),
+		Nonce:    rand.Text(),
+		Verifier: oauth.GenerateVerifier(),
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	value, err := json.Marshal(flow)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.cookies.setOAuthFlow(e, base64.RawURLEncoding.EncodeToString(value), oauthFlowTTL)
+
+	return e.Redirect(http.StatusFound, authURL)
+}
+
+func (app *application) oauthCallback(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 15*time.Second)
+	defer cancel()
+
+	provider, ok := app.oauthProviders[e.Param("provider")]
+	if !ok {
+		return app.notFoundResponse(e)
+	}
+
+	flow, err := app.readOAuthFlowCookie(e)
+
+	// The flow cookie is single use.
+	app.cookies.clearOAuthFlow(e)
+
+	if e.QueryParam("error") != "" {
+		return app.redirectOAuthError(e, oauthErrorAccessDenied)
+	}
+
+	if err != nil || flow.Provider != provider.Name() ||
+		subtle.ConstantTimeCompare([]byte(flow.State), []byte(e.QueryParam("state"))) != 1 {
+		return app.redirectOAuthError(e, oauthErrorInvalidState)
+	}
+
+	identity, err := provider.Exchange(ctx, e.QueryParam("code"), flow.Nonce, flow.Verifier)
+	if err != nil {
+		app.logger.Warn("oauth code exchange failed", "provider", provider.Name(), "error", err.Error())
+		return app.redirectOAuthError(e, oauthErrorExchangeFailed)
+	}
+
+	user, err := app.userForOAuthIdentity(ctx, provider.Name(), identity)
+	if err != nil {
+		switch {
+		case errors.Is(err, errOAuthEmailRequired):
+			return app.redirectOAuthError(e, oauthErrorEmailRequired)
+		case errors.Is(err, data.ErrDuplicateEmail):
+			return app.redirectOAuthError(e, oauthErrorAccountExists)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	// Logins through a provider are held to the lockout like all others.
+	attempts, err := app.models.Logins.Get(ctx, user.Email)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	if attempts.Locked() {
+		return app.redirectOAuthError(e, oauthErrorAccountLocked)
+	}
+
+	if user.Disabled {
+		return app.redirectOAuthError(e, oauthErrorAccountDisabled)
+	}
+
+	mfaEnabled, err := app.models.TwoFactor.IsEnabledForUser(ctx, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	if mfaEnabled {
+		challengeToken, err := app.models.Tokens.New(ctx, user.ID, mfaChallengeTokenTTL, data.ScopeMFAChallenge)
+		if err != nil {
+			return app.serverErrorResponse(e, err)
+		}
+
+		// The challenge token is passed in the fragment so that it never reaches a server log.
+		return e.Redirect(http.StatusFound, app.config.oauth.frontendCallbackURL+"#mfa_token="+challengeToken.Plaintext)
+	}
+
+	// The frontend obtains its authentication token from the refresh token cookie set here.
+	_, err = app.logIn(ctx, e, user, "")
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.Redirect(http.StatusFound, app.config.oauth.frontendCallbackURL)
+}
+
+// userForOAuthIdentity returns the user linked to the identity. An identity seen for the first
+// time is linked to the account with the same email address, or a new account is created.
+func (app *application) userForOAuthIdentity(ctx context.Context, provider string, identity *oauth.Identity) (*data.User, error) {
+	user, err := app.models.Identities.GetUser(ctx, provider, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if !validator.Matches(identity.Email, validator.EmailRX) {
+		return nil, errOAuthEmailRequired
+	}
+
+	userIdentity := &data.UserIdentity{
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+
+	user, err = app.models.Users.GetByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		// Only link to an existing account if the provider vouches for the address. Otherwise
+		// anyone could take over an account by signing up at the provider with its email.
+		if !identity.EmailVerified {
+			return nil, data.ErrDuplicateEmail
+		}
+
+		// An unactivated account may have been registered by someone else before the owner
+		// of the address, who has just proven it. Nothing that person set up may survive.
+		if !user.Activated {
+			err = app.models.Users.Claim(ctx, user)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		userIdentity.UserID = user.ID
+		err = app.models.Identities.Insert(ctx, userIdentity)
+		if err != nil {
+			return nil, err
+		}
+
+		return user, nil
+	case errors.Is(err, data.ErrRecordNotFound):
+		return app.registerOAuthUser(ctx, identity, userIdentity)
+	default:
+		return nil, err
+	}
+}
+
+func (app *application) registerOAuthUser(ctx context.Context, identity *oauth.Identity, userIdentity *data.UserIdentity) (*data.User, error) {
+	fullName := strings.TrimSpace(identity.Name)
+	if fullName == "" {
+		fullName, _, _ = strings.Cut(identity.Email, "@")
+	}
+	if runes := []rune(fullName); len(runes) > 32 {
+		fullName = string(runes[:32])
+	}
+
+	user := &data.User{
+		FullName:  fullName,
+		Email:     identity.Email,
+		Activated: identity.EmailVerified,
+	}
+	app.models.Users.SetOAuthPasswordPlaceholder(user)
+
+	err := app.models.Identities.InsertWithUser(ctx, user, userIdentity)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.Activated {
+		token, err := app.models.Tokens.New(ctx, user.ID, 3*24*time.Hour, data.ScopeActivation)
+		if err != nil {
+			return nil, err
+		}
+
+		app.background(func() {
+			data := map[string]any{
+				"activationToken": token.Plaintext,
+				"fullName":        user.FullName,
+			}
+			err := app.mailer.Send(user.Email, "user_welcome.tmpl", data)
+			if err != nil {
+				app.logger.Error(err.Error())
+			}
+		})
+	}
+
+	return user, nil
+}
+
+func (app *application) readOAuthFlowCookie(e echo.Context) (*oauthFlow, error) {
+	cookie, err := e.Cookie(oauthFlowCookieName)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	var flow oauthFlow
+	err = json.Unmarshal(value, &flow)
+	if err != nil {
+		return nil, err
+	}
+
+	return &flow, nil
+}
+
+func (app *application) redirectOAuthError(e echo.Context, code string) error {
+	return e.Redirect(http.StatusFound, app.config.oauth.frontendCallbackURL+"?error="+url.QueryEscape(code))
+}
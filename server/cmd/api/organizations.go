@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/validator"
+	"github.com/labstack/echo/v4"
+)
+
+// organizationHeader names the active organization of a request.
+const organizationHeader = "X-Organization-ID"
+
+// orgPermissions returns the permissions of the session in the active organization. Scoped
+// API keys are restricted there just as they are globally.
+func (app *application) orgPermissions(session *data.Session) data.Permissions {
+	if session.Membership == nil {
+		return data.Permissions{}
+	}
+
+	if session.APIKey != nil {
+		return session.APIKey.Restrict(session.Membership.Permissions)
+	}
+	return session.Membership.Permissions
+}
+
+// requireGrantableOrgRole checks that the authenticated user may grant or revoke the role in
+// the active organization. On failure the returned error is the response to send.
+func (app *application) requireGrantableOrgRole(ctx context.Context, e echo.Context, role data.Role) error {
+	rolePermissions, err := app.models.Permissions.GetAllForRoleCode(ctx, role)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	session := app.contextGetSession(e)
+	if !data.CanGrantRole(session.Roles, app.orgPermissions(session), role, rolePermissions) {
+		return app.notPermittedResponse(e)
+	}
+
+	return nil
+}
+
+func (app *application) listCurrentUserOrganizations(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	session := app.contextGetSession(e)
+
+	orgs, err := app.models.Organizations.GetAllForUser(ctx, session.UserID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{"organizations": orgs})
+}
+
+func (app *application) createOrganization(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var input struct {
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	org := &data.Organization{
+		Name: input.Name,
+		Slug: input.Slug,
+	}
+
+	v := validator.New()
+
+	if data.ValidateOrganization(v, localizer, org); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	session := app.contextGetSession(e)
+
+	err := app.models.Organizations.New(ctx, org, session.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateOrganizationSlug):
+			v.AddError("slug", i18n.LocalizeMessage(localizer, "OrganizationSlugAlreadyExists", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	e.Response().Header().Set("ETag", versionETag(org.Version))
+	return e.JSON(http.StatusCreated, echo.Map{"organization": org})
+}
+
+func (app *application) showOrganization(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	session := app.contextGetSession(e)
+
+	org, err := app.models.Organizations.Get(ctx, session.OrganizationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	if session.Membership != nil {
+		org.Role = session.Membership.Role
+	}
+
+	e.Response().Header().Set("ETag", versionETag(org.Version))
+	return e.JSON(http.StatusOK, echo.Map{
+		"organization": org,
+		"permissions":  app.orgPermissions(session),
+	})
+}
+
+func (app *application) updateOrganization(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var input struct {
+		Name *string `json:"name"`
+		Slug *string `json:"slug"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	session := app.contextGetSession(e)
+
+	org, err := app.models.Organizations.Get(ctx, session.OrganizationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	if !ifMatch(e, versionETag(org.Version)) {
+		return app.preconditionFailedResponse(e)
+	}
+
+	if input.Name != nil {
+		org.Name = *input.Name
+	}
+	if input.Slug != nil {
+		org.Slug = *input.Slug
+	}
+
+	v := validator.New()
+
+	if data.ValidateOrganization(v, localizer, org); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	err = app.models.Organizations.Update(ctx, org)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateOrganizationSlug):
+			v.AddError("slug", i18n.LocalizeMessage(localizer, "OrganizationSlugAlreadyExists", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			return app.editConflictResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	e.Response().Header().Set("ETag", versionETag(org.Version))
+	return e.JSON(http.StatusOK, echo.Map{"organization": org})
+}
+
+func (app *application) deleteOrganization(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	session := app.contextGetSession(e)
+
+	org, err := app.models.Organizations.Get(ctx, session.OrganizationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	err = app.models.Organizations.Delete(ctx, org.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	app.audit(ctx, e, data.AuditOrgDeleted, session.UserID, 0, map[string]any{
+		"organization_id":   org.ID,
+		"organization_name": org.Name,
+	})
+
+	return e.NoContent(http.StatusOK)
+}
+
+func (app *application) listOrganizationMembers(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	session := app.contextGetSession(e)
+
+	members, err := app.models.Organizations.GetAllMembers(ctx, session.OrganizationID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{"members": members})
+}
+
+// updateOrganizationMember changes the role of a member. Both the current and the new role
+// must be grantable by the caller, so that nobody can demote or promote past their own rank.
+func (app *application) updateOrganizationMember(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var input struct {
+		Role string `json:"role"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	id, err := app.readIDParam(e)
+	if err != nil {
+		return app.notFoundResponse(e)
+	}
+
+	session := app.contextGetSession(e)
+
+	member, err := app.models.Organizations.GetMembership(ctx, session.OrganizationID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	role := data.Role(input.Role)
+
+	v := validator.New()
+
+	if v.Check(validator.NotBlank(input.Role), "role", i18n.LocalizeMessage(localizer, "RoleMustBeProvided", nil)); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	err = app.models.Roles.Exists(ctx, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("role", i18n.LocalizeMessage(localizer, "RoleNotFound", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	err = app.requireGrantableOrgRole(ctx, e, member.Role)
+	if err != nil {
+		return err
+	}
+
+	err = app.requireGrantableOrgRole(ctx, e, role)
+	if err != nil {
+		return err
+	}
+
+	rolePermissions, err := app.models.Permissions.GetAllForRoleCode(ctx, role)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	// A new role that adds to what the member could do is a promotion. Otherwise the member
+	// is told about the role they lost.
+	granted := !member.Permissions.HasAll(rolePermissions...)
+	action, noticeRole := data.AuditRoleGranted, role
+	if !granted {
+		action, noticeRole = data.AuditRoleRevoked, member.Role
+	}
+
+	user, err := app.models.Users.GetByID(ctx, id)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	org, err := app.models.Organizations.Get(ctx, session.OrganizationID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	err = app.models.Organizations.UpdateMemberRole(ctx, session.OrganizationID, id, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		case errors.Is(err, data.ErrLastOwner):
+			return app.lastOwnerResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	app.audit(ctx, e, action, session.UserID, id, map[string]any{
+		"role":            role,
+		"previous_role":   member.Role,
+		"organization_id": session.OrganizationID,
+	})
+	if role != member.Role {
+		app.notify(e, user, "role_changed.tmpl", map[string]any{
+			"role":             noticeRole,
+			"granted":          granted,
+			"organizationName": org.Name,
+		})
+	}
+
+	member.Role = role
+	return e.JSON(http.StatusOK, echo.Map{"member": member})
+}
+
+// removeOrganizationMember removes a member from the active organization. Members can always
+// leave on their own; removing anyone else takes members:manage.
+func (app *application) removeOrganizationMember(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	id, err := app.readIDParam(e)
+	if err != nil {
+		return app.notFoundResponse(e)
+	}
+
+	session := app.contextGetSession(e)
+
+	if id != session.UserID {
+		if !session.Roles.Has(data.RoleSuperAdmin) && !app.orgPermissions(session).Has(data.PermMembersManage) {
+			return app.notPermittedResponse(e)
+		}
+
+		member, err := app.models.Organizations.GetMembership(ctx, session.OrganizationID, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				return app.notFoundResponse(e)
+			default:
+				return app.serverErrorResponse(e, err)
+			}
+		}
+
+		err = app.requireGrantableOrgRole(ctx, e, member.Role)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = app.models.Organizations.RemoveMember(ctx, session.OrganizationID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		case errors.Is(err, data.ErrLastOwner):
+			return app.lastOwnerResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	app.audit(ctx, e, data.AuditOrgMemberRemoved, session.UserID, id, map[string]any{
+		"organization_id": session.OrganizationID,
+	})
+
+	return e.NoContent(http.StatusOK)
+}
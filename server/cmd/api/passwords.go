@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"adcentra.ai/internal/hasher"
+	"adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/validator"
+	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// newBreachedPasswords returns where breached passwords are looked up: the Pwned Passwords
+// API for "hibp", the file at the path otherwise, and nowhere if the source is empty.
+func newBreachedPasswords(cfg config) (validator.BreachedPasswords, error) {
+	switch cfg.passwords.breachSource {
+	case "":
+		return nil, nil
+	case "hibp":
+		return validator.NewHIBPRange(), nil
+	default:
+		return validator.NewFileRange(cfg.passwords.breachSource)
+	}
+}
+
+// newPasswordHasher returns the hasher for user passwords. New hashes are made with the
+// configured algorithm; hashes made with the other one are still verified.
+func newPasswordHasher(cfg config) (*hasher.Hasher, error) {
+	argon2id := hasher.Argon2id{
+		Memory:      uint32(cfg.passwords.argon2id.memory),
+		Iterations:  uint32(cfg.passwords.argon2id.iterations),
+		Parallelism: uint8(cfg.passwords.argon2id.parallelism),
+		SaltLength:  hasher.DefaultArgon2id.SaltLength,
+		KeyLength:   hasher.DefaultArgon2id.KeyLength,
+	}
+	if argon2id.Iterations < 1 || argon2id.Parallelism < 1 || cfg.passwords.argon2id.parallelism > 255 || argon2id.Memory < 8*uint32(argon2id.Parallelism) {
+		return nil, fmt.Errorf("invalid argon2id parameters: m=%d, t=%d, p=%d", cfg.passwords.argon2id.memory, cfg.passwords.argon2id.iterations, cfg.passwords.argon2id.parallelism)
+	}
+
+	bcrypt := hasher.Bcrypt{Cost: cfg.passwords.bcryptCost}
+	if bcrypt.Cost < 4 || bcrypt.Cost > 31 {
+		return nil, fmt.Errorf("invalid bcrypt cost %d", bcrypt.Cost)
+	}
+
+	switch cfg.passwords.hashAlgorithm {
+	case argon2id.Name():
+		return hasher.New(argon2id, bcrypt), nil
+	case bcrypt.Name():
+		return hasher.New(bcrypt, argon2id), nil
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", cfg.passwords.hashAlgorithm)
+	}
+}
+
+// validatePasswordNotBreached checks that the password hasn't appeared in a data breach. If
+// the lookup fails, the failure is logged and the password let through, so that an
+// unreachable breach source doesn't stop users from signing up or changing passwords.
+func (app *application) validatePasswordNotBreached(ctx context.Context, v *validator.Validator, localizer *goi18n.Localizer, password string) {
+	if app.breachedPasswords == nil {
+		return
+	}
+
+	count, err := validator.BreachCount(ctx, app.breachedPasswords, password)
+	if err != nil {
+		app.logger.Warn("failed to look up breached password", "error", err.Error())
+		return
+	}
+
+	v.Check(count == 0, "password", i18n.LocalizeMessage(localizer, "PasswordHasBeenBreached", nil))
+}
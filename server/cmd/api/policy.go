@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/policy"
+	"github.com/labstack/echo/v4"
+)
+
+// authorize checks the action on the resource against the policy of the API. The active
+// organization is optional here: without it only globally held permissions count. On
+// failure the returned error is the response to send.
+func (app *application) authorize(ctx context.Context, e echo.Context, action policy.Action, resource policy.Resource) error {
+	session := app.contextGetSession(e)
+
+	subject := policy.Subject{
+		UserID:      session.UserID,
+		Roles:       session.Roles,
+		Permissions: session.Permissions(),
+	}
+
+	if header := e.Request().Header.Get(organizationHeader); header != "" {
+		orgID, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || orgID < 1 {
+			return app.organizationRequiredResponse(e)
+		}
+
+		if session.Membership == nil || session.OrganizationID != orgID {
+			membership, err := app.models.Organizations.GetMembership(ctx, orgID, session.UserID)
+			if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+				return app.serverErrorResponse(e, err)
+			}
+
+			session.OrganizationID = orgID
+			session.Membership = membership
+		}
+
+		subject.OrganizationID = orgID
+		subject.OrgPermissions = app.orgPermissions(session)
+	}
+
+	if !app.policy.Can(subject, action, resource) {
+		return app.notPermittedResponse(e)
+	}
+
+	return nil
+}
+
+// userResource describes the user for the policy: their global roles and permissions and
+// the organizations they are a member of.
+func (app *application) userResource(ctx context.Context, user *data.User) (policy.Resource, error) {
+	roles, err := app.models.Roles.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return policy.Resource{}, err
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return policy.Resource{}, err
+	}
+
+	orgs, err := app.models.Organizations.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return policy.Resource{}, err
+	}
+
+	resource := policy.Resource{
+		OwnerID:          user.ID,
+		OwnerRoles:       roles,
+		OwnerPermissions: permissions,
+		OrganizationIDs:  make([]int64, len(orgs)),
+	}
+	for i, org := range orgs {
+		resource.OrganizationIDs[i] = org.ID
+	}
+	return resource, nil
+}
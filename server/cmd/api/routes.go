@@ -29,7 +29,8 @@ func (app *application) routes() *echo.Echo {
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins:     app.config.cors.trustedOrigins,
 		AllowMethods:     []string{echo.GET, echo.HEAD, echo.PUT, echo.PATCH, echo.POST, echo.DELETE, echo.OPTIONS},
-		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAuthorization},
+		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAuthorization, "If-Match", organizationHeader, csrfTokenHeader},
+		ExposeHeaders:    []string{"ETag", csrfTokenHeader},
 		AllowCredentials: true,
 	}))
 	if app.config.limiter.enabled {
@@ -50,15 +51,81 @@ func (app *application) routes() *echo.Echo {
 	g.POST("/users", app.registerUser)
 	g.PUT("/users/activate", app.activateUser)
 	g.PUT("/users/password", app.updateUserPassword)
+	g.PUT("/users/email", app.confirmEmailChange)
+
+	g.POST("/invitations/accept", app.acceptInvitation)
 
 	g.POST("/tokens/authentication", app.createAuthenticationToken)
+	g.POST("/tokens/mfa", app.createAuthenticationTokenFromMFAChallenge)
+	g.POST("/tokens/magic-link", app.createMagicLinkToken)
+	g.POST("/tokens/magic-link/authentication", app.createAuthenticationTokenFromMagicLink)
 	g.POST("/tokens/refresh", app.refreshAuthenticationToken).Name = "refresh-token"
 	g.POST("/tokens/activation", app.createActivationToken)
 	g.POST("/tokens/password-reset", app.createPasswordResetToken)
 
+	g.GET("/oauth/:provider/start", app.startOAuthLogin)
+	g.GET("/oauth/:provider/callback", app.oauthCallback)
+
+	// Sensitive operations also need the user to have proven their identity recently.
+	recentAuth := app.requireRecentAuth(app.config.reauthentication.maxAge)
+
 	a := g.Group("", app.requireAuthentication)
 	a.GET("/me", app.getCurrentUser)
+	a.PATCH("/me", app.updateCurrentUser)
+	a.DELETE("/me", app.deleteCurrentUser, recentAuth)
+	a.GET("/me/export", app.exportCurrentUser)
+	a.PUT("/me/password", app.changeCurrentUserPassword)
+	a.POST("/me/email", app.requestEmailChange, recentAuth)
+	a.GET("/me/sessions", app.listCurrentUserSessions)
+	a.DELETE("/me/sessions/:id", app.deleteCurrentUserSession)
+	a.GET("/me/api-keys", app.listCurrentUserAPIKeys)
+	a.POST("/me/api-keys", app.createCurrentUserAPIKey, recentAuth)
+	a.DELETE("/me/api-keys/:id", app.deleteCurrentUserAPIKey)
+	a.POST("/me/2fa", app.enrollTwoFactor, recentAuth)
+	a.POST("/me/2fa/verify", app.enableTwoFactor, recentAuth)
+	a.POST("/me/2fa/recovery-codes", app.regenerateRecoveryCodes, recentAuth)
+	a.DELETE("/me/2fa", app.disableTwoFactor, recentAuth)
+	a.POST("/tokens/reauthenticate", app.reauthenticate)
 	a.DELETE("/tokens/authentication", app.deleteAuthenticationToken)
 
+	a.GET("/organizations", app.listCurrentUserOrganizations)
+	a.POST("/organizations", app.createOrganization, app.requireActivation)
+
+	// Routes below act on the organization named by the X-Organization-ID header.
+	org := a.Group("", app.requireActivation)
+	org.GET("/organization", app.showOrganization, app.requireOrgPermissions(data.PermOrgView))
+	org.PATCH("/organization", app.updateOrganization, app.requireOrgPermissions(data.PermOrgManage))
+	org.DELETE("/organization", app.deleteOrganization, app.requireOrgPermissions(data.PermOrgManage), recentAuth)
+	org.GET("/organization/members", app.listOrganizationMembers, app.requireOrgPermissions(data.PermMembersView))
+	org.PATCH("/organization/members/:id", app.updateOrganizationMember, app.requireOrgPermissions(data.PermMembersManage), recentAuth)
+	org.DELETE("/organization/members/:id", app.removeOrganizationMember, app.requireOrgPermissions())
+	org.GET("/invitations", app.listInvitations, app.requireOrgPermissions(data.PermMembersView))
+	org.POST("/invitations", app.createInvitation, app.requireOrgPermissions(data.PermMembersManage))
+	org.DELETE("/invitations/:id", app.deleteInvitation, app.requireOrgPermissions(data.PermMembersManage))
+
+	// The user routes below are authorized by the policy in their handlers, so that
+	// permissions held through an organization role apply to its members.
+	admin := a.Group("/admin", app.requireActivation)
+	admin.GET("/users", app.listUsers, app.requirePermissions(data.PermUsersView))
+	admin.GET("/users/:id", app.showUser)
+	admin.PATCH("/users/:id", app.updateUser)
+	admin.PUT("/users/:id/activation", app.updateUserActivation, recentAuth)
+	admin.DELETE("/users/:id/sessions", app.deleteUserSessions, recentAuth)
+	admin.POST("/users/:id/password-reset", app.createUserPasswordResetToken, recentAuth)
+	admin.DELETE("/users/:id/lockout", app.clearUserLockout, recentAuth)
+	admin.GET("/permissions", app.listPermissions, app.requirePermissions(data.PermRolesView))
+	admin.GET("/roles", app.listRoles, app.requirePermissions(data.PermRolesView))
+	admin.POST("/roles", app.createRole, app.requirePermissions(data.PermRolesManage), recentAuth)
+	admin.GET("/roles/:code", app.showRole, app.requirePermissions(data.PermRolesView))
+	admin.PATCH("/roles/:code", app.updateRole, app.requirePermissions(data.PermRolesManage))
+	admin.DELETE("/roles/:code", app.deleteRole, app.requirePermissions(data.PermRolesManage), recentAuth)
+	admin.GET("/roles/:code/permissions", app.listRolePermissions, app.requirePermissions(data.PermRolesView))
+	admin.POST("/roles/:code/permissions", app.addRolePermissions, app.requirePermissions(data.PermRolesManage), recentAuth)
+	admin.DELETE("/roles/:code/permissions/:permission", app.removeRolePermission, app.requirePermissions(data.PermRolesManage), recentAuth)
+	admin.GET("/roles/:code/users", app.listRoleUsers, app.requirePermissions(data.PermRolesView))
+	admin.POST("/roles/:code/users", app.addRoleUser, app.requirePermissions(data.PermRolesManage), recentAuth)
+	admin.DELETE("/roles/:code/users/:id", app.removeRoleUser, app.requirePermissions(data.PermRolesManage), recentAuth)
+	admin.GET("/audit", app.listAuditEvents, app.requirePermissions(data.PermAuditView))
+
 	return e
 }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"adcentra.ai/internal/data"
+	"github.com/labstack/echo/v4"
+)
+
+// startSession records a new device session for the user and issues the first refresh and
+// authentication tokens for it. If no device name is given, one is derived from the User-Agent.
+func (app *application) startSession(ctx context.Context, e echo.Context, user *data.User, deviceName string) (*data.Token, *data.Token, error) {
+	userAgent := e.Request().UserAgent()
+	if deviceName == "" {
+		deviceName = deviceNameFromUserAgent(userAgent)
+	}
+
+	session, err := app.models.Sessions.New(ctx, user.ID, deviceName, userAgent, e.RealIP(), time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokenFamily := data.NewTokenFamily()
+
+	refreshToken, err := app.models.Tokens.NewForSession(ctx, session, refreshTokenTTL, data.ScopeRefresh, tokenFamily)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authToken, err := app.newAuthenticationToken(ctx, session, user, tokenFamily)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return refreshToken, authToken, nil
+}
+
+func (app *application) listCurrentUserSessions(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	current := app.contextGetSession(e)
+
+	sessions, err := app.models.Sessions.GetAllForUser(ctx, current.User.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == current.ID
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{
+		"sessions": sessions,
+	})
+}
+
+func (app *application) deleteCurrentUserSession(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	id, err := app.readIDParam(e)
+	if err != nil {
+		return app.notFoundResponse(e)
+	}
+
+	current := app.contextGetSession(e)
+
+	err = app.models.Sessions.Delete(ctx, id, current.User.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.notFoundResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	app.audit(ctx, e, data.AuditLogout, current.UserID, current.UserID, map[string]any{"session_id": id})
+
+	if id == current.ID {
+		app.models.Permissions.InvalidateAllForUserCache(ctx, current.User.ID)
+		app.models.Roles.InvalidateAllForUserCache(ctx, current.User.ID)
+	}
+
+	return e.NoContent(http.StatusOK)
+}
+
+// deviceNameFromUserAgent builds a human readable device name such as "Chrome on macOS"
+// from a User-Agent header. It is only meant for display, not for identifying clients.
+func deviceNameFromUserAgent(userAgent string) string {
+	var browser, os string
+
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	switch {
+	case strings.Contains(userAgent, "iPhone"):
+		os = "iPhone"
+	case strings.Contains(userAgent, "iPad"):
+		os = "iPad"
+	case strings.Contains(userAgent, "Android"):
+		os = "Android"
+	case strings.Contains(userAgent, "Windows"):
+		os = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"):
+		os = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		os = "Linux"
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	default:
+		return "Unknown device"
+	}
+}
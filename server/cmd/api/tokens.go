@@ -10,18 +10,19 @@ import (
 	"adcentra.ai/internal/data"
 	"adcentra.ai/internal/i18n"
 	"adcentra.ai/internal/validator"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo/v4"
 )
 
 const (
-	deleteGlobalSessionScope = "global"
-	deleteLocalSessionScope  = "local"
-	deleteOthersSessionScope = "others"
-
 	authTokenTTL          = 1 * time.Minute
 	refreshTokenTTL       = 15 * 24 * time.Hour
 	activationTokenTTL    = 12 * time.Hour
 	passwordResetTokenTTL = 45 * time.Minute
+	mfaChallengeTokenTTL  = 5 * time.Minute
+	magicLinkTokenTTL     = 15 * time.Minute
+	emailChangeTokenTTL   = 1 * time.Hour
+	invitationTokenTTL    = 7 * 24 * time.Hour
 )
 
 func (app *application) createAuthenticationToken(e echo.Context) error {
@@ -30,8 +31,9 @@ func (app *application) createAuthenticationToken(e echo.Context) error {
 	defer cancel()
 
 	var input struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		DeviceName string `json:"device_name"`
 	}
 
 	if err := e.Bind(&input); err != nil {
@@ -42,13 +44,22 @@ func (app *application) createAuthenticationToken(e echo.Context) error {
 
 	data.ValidateEmail(v, localizer, input.Email)
 	v.Check(validator.NotBlank(input.Password), "password", i18n.LocalizeMessage(localizer, "PasswordRequired", nil))
+	data.ValidateDeviceName(v, localizer, input.DeviceName)
 
 	if !v.Valid() {
 		return app.failedValidationResponse(e, v.Errors)
 	}
 
+	attempts, err := app.models.Logins.Get(ctx, input.Email)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	if attempts.Locked() {
+		return app.accountLockedResponse(e, attempts.RetryAfter())
+	}
+
 	var user *data.User
-	var err error
 	var authSuccess bool
 	user, err = app.models.Users.GetByEmail(ctx, input.Email)
 	if err != nil {
@@ -56,35 +67,157 @@ func (app *application) createAuthenticationToken(e echo.Context) error {
 		case errors.Is(err, data.ErrRecordNotFound):
 			// If the user does not exist, run a dummy match to prevent timing attacks on email.
 			user = &data.User{}
-			user.Password.DummyMatches(input.Password)
+			app.models.Users.DummyPasswordMatches(input.Password)
 			authSuccess = false
 		default:
 			return app.serverErrorResponse(e, err)
 		}
 	} else {
-		authSuccess, err = user.Password.Matches(input.Password)
+		authSuccess, err = app.models.Users.PasswordMatches(user, input.Password)
 		if err != nil {
 			return app.serverErrorResponse(e, err)
 		}
 	}
 
 	if !authSuccess {
-		return app.invalidCredentialsResponse(e)
+		return app.failedLoginResponse(ctx, e, input.Email, user)
+	}
+
+	// Only told to those who know the password, so that it doesn't reveal the account.
+	if user.Disabled {
+		return app.accountDisabledResponse(e)
+	}
+
+	// The plaintext is only at hand now, so this is when a hash made with an older algorithm
+	// or weaker parameters gets replaced. Failing to do so doesn't fail the login.
+	if user.Password.Outdated() {
+		err = app.models.Users.SetPassword(user, input.Password)
+		if err == nil {
+			err = app.models.Users.Update(ctx, user)
+		}
+		if err != nil {
+			app.logger.Warn("failed to rehash password", "user_id", user.ID, "error", err.Error())
+		}
+	}
+
+	if attempts.FailedCount > 0 {
+		err = app.models.Logins.Reset(ctx, input.Email)
+		if err != nil {
+			return app.serverErrorResponse(e, err)
+		}
 	}
 
-	refreshToken, err := app.models.Tokens.New(ctx, user.ID, refreshTokenTTL, data.ScopeRefresh)
+	return app.beginLogin(ctx, e, user, input.DeviceName)
+}
+
+// beginLogin is called once the user has proven the first factor. Users with two-factor
+// authentication get a short-lived challenge token instead, which has to be exchanged
+// together with a valid code for the actual token pair.
+func (app *application) beginLogin(ctx context.Context, e echo.Context, user *data.User, deviceName string) error {
+	localizer := app.contextGetLocalizer(e)
+
+	mfaEnabled, err := app.models.TwoFactor.IsEnabledForUser(ctx, user.ID)
 	if err != nil {
 		return app.serverErrorResponse(e, err)
 	}
 
-	authToken, err := app.models.Tokens.New(ctx, user.ID, authTokenTTL, data.ScopeAuthentication)
+	if mfaEnabled {
+		challengeToken, err := app.models.Tokens.New(ctx, user.ID, mfaChallengeTokenTTL, data.ScopeMFAChallenge)
+		if err != nil {
+			return app.serverErrorResponse(e, err)
+		}
+
+		return e.JSON(http.StatusAccepted, echo.Map{
+			"message":      i18n.LocalizeMessage(localizer, "TwoFactorRequired", nil),
+			"mfa_required": true,
+			"mfa_token":    challengeToken,
+		})
+	}
+
+	return app.completeLogin(ctx, e, user, deviceName)
+}
+
+func (app *application) createAuthenticationTokenFromMFAChallenge(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var input struct {
+		TokenPlainText string `json:"token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+		DeviceName     string `json:"device_name"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	v := validator.New()
+
+	data.ValidateTokenPlaintext(v, localizer, input.TokenPlainText)
+	if input.RecoveryCode == "" {
+		data.ValidateTwoFactorCode(v, localizer, input.Code)
+	}
+	data.ValidateDeviceName(v, localizer, input.DeviceName)
+
+	if !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	user, err := app.models.Users.GetForToken(ctx, data.ScopeMFAChallenge, input.TokenPlainText)
 	if err != nil {
-		return app.serverErrorResponse(e, err)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.invalidCredentialsResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
 	}
 
-	user.LastLoginAt.Time = time.Now()
-	user.LastLoginAt.Valid = true
-	err = app.models.Users.Update(ctx, user)
+	if user.Disabled {
+		return app.accountDisabledResponse(e)
+	}
+
+	tf, err := app.models.TwoFactor.GetForUser(ctx, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.invalidCredentialsResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	if input.RecoveryCode != "" {
+		err = app.models.TwoFactor.UseRecoveryCode(ctx, user.ID, input.RecoveryCode)
+	} else {
+		err = app.models.TwoFactor.Verify(ctx, tf, input.Code)
+	}
+
+	// The challenge token is single use whatever the outcome, so that codes can't be brute
+	// forced within its lifetime without also knowing the password.
+	if deleteErr := app.models.Tokens.DeleteAllForUser(ctx, data.ScopeMFAChallenge, user.ID); deleteErr != nil {
+		return app.serverErrorResponse(e, deleteErr)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidTwoFactorCode):
+			app.audit(ctx, e, data.AuditLoginFailed, 0, user.ID, map[string]any{"reason": "two_factor"})
+			return app.invalidTwoFactorCodeResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	return app.completeLogin(ctx, e, user, input.DeviceName)
+}
+
+// completeLogin logs in a user who has proven their identity and responds with a new
+// authentication token.
+func (app *application) completeLogin(ctx context.Context, e echo.Context, user *data.User, deviceName string) error {
+	authToken, err := app.logIn(ctx, e, user, deviceName)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -94,79 +227,181 @@ func (app *application) createAuthenticationToken(e echo.Context) error {
 		}
 	}
 
-	e.SetCookie(&http.Cookie{
-		Name:     "refresh_token",
-		Value:    refreshToken.Plaintext,
-		Expires:  refreshToken.Expiry.Time,
-		Path:     "/v1/",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
-	})
-
 	return e.JSON(http.StatusCreated, echo.Map{
 		"authentication_token": authToken,
 		"user":                 user,
 	})
 }
 
+// logIn starts a device session for the user, records the login time and sets the refresh
+// token cookie. Logging in also cancels a pending account deletion, and the user is notified
+// when it happens on a device they haven't used before. It returns the authentication token
+// of the new session.
+func (app *application) logIn(ctx context.Context, e echo.Context, user *data.User, deviceName string) (*data.Token, error) {
+	// Checked before this login is recorded, which would make the device known.
+	newDevice, err := app.models.Audit.IsNewDevice(ctx, user.ID, e.Request().UserAgent())
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, authToken, err := app.startSession(ctx, e, user, deviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	user.LastLoginAt.Time = time.Now()
+	user.LastLoginAt.Valid = true
+	user.DeletionRequestedAt = pgtype.Timestamptz{}
+	err = app.models.Users.Update(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	app.audit(ctx, e, data.AuditLogin, user.ID, user.ID, map[string]any{
+		"session_id":  authToken.SessionID.Int64,
+		"device_name": deviceName,
+	})
+
+	if newDevice {
+		notice := map[string]any{}
+		if deviceName != "" {
+			notice["device"] = deviceName
+		}
+		app.notify(e, user, "new_device_login.tmpl", notice)
+	}
+
+	app.cookies.setRefreshToken(e, refreshToken)
+
+	return authToken, nil
+}
+
 func (app *application) deleteAuthenticationToken(e echo.Context) error {
 	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
 	defer cancel()
 
 	session := app.contextGetSession(e)
 
-	deleteSessionScope := e.QueryParam("scope")
-	// Get the refresh token from the cookie so that the refresh token of the session
-	// can be deleted if the logout scope is local (or avoid deleting if logout scope is others)
-	refreshTokenCookie, err := e.Cookie("refresh_token")
-	if err != nil {
-		return app.invalidCredentialsResponse(e)
-	}
-	refreshToken := refreshTokenCookie.Value
-
-	switch deleteSessionScope {
-	case deleteLocalSessionScope:
-		if refreshToken != "" && len(refreshToken) == 26 {
-			refreshTokenHash := sha256.Sum256([]byte(refreshToken))
+	var err error
+	switch {
+	case session.ID != 0:
+		// Revoke the device session, which deletes every token issued for it.
+		err = app.models.Sessions.Delete(ctx, session.ID, session.User.ID)
+		if errors.Is(err, data.ErrRecordNotFound) {
+			err = nil
+		}
+	case session.Token == nil:
+		// Signed access tokens always belong to a session and API keys are revoked on their
+		// own, so there is nothing else to revoke.
+	case session.Token.Family != nil:
+		err = app.models.Tokens.DeleteFamily(ctx, session.Token.Family)
+	default:
+		// Tokens issued before device sessions and token families existed are revoked one by one.
+		refreshTokenCookie, cookieErr := e.Cookie(refreshTokenCookieName)
+		if cookieErr == nil && len(refreshTokenCookie.Value) == 26 {
+			refreshTokenHash := sha256.Sum256([]byte(refreshTokenCookie.Value))
 			err = app.models.Tokens.DeleteByHash(ctx, refreshTokenHash[:])
 		}
 		if err == nil {
 			err = app.models.Tokens.DeleteByHash(ctx, session.Token.Hash)
 		}
-	case deleteGlobalSessionScope:
-		// Delete all refresh tokens for the user
-		err = app.models.Tokens.DeleteAllForUser(ctx, data.ScopeRefresh, session.User.ID)
-		if err == nil {
-			// Delete all authentication tokens for the user
-			err = app.models.Tokens.DeleteAllForUser(ctx, data.ScopeAuthentication, session.User.ID)
-		}
-	case deleteOthersSessionScope:
-		// Delete all refresh tokens for the user except the current one
-		if refreshToken != "" && len(refreshToken) == 26 {
-			refreshTokenHash := sha256.Sum256([]byte(refreshToken))
-			err = app.models.Tokens.DeleteAllForUserExcept(ctx, data.ScopeRefresh, session.User.ID, refreshTokenHash[:])
-		}
-		if err == nil {
-			// Delete all authentication tokens for the user except the current one
-			err = app.models.Tokens.DeleteAllForUserExcept(ctx, data.ScopeAuthentication, session.User.ID, session.Token.Hash)
-		}
-	default:
-		v := validator.New()
-		v.AddError("scope", "invalid scope")
-		return app.failedValidationResponse(e, v.Errors)
 	}
 	if err != nil {
 		return app.serverErrorResponse(e, err)
 	}
+	app.audit(ctx, e, data.AuditLogout, session.UserID, session.UserID, map[string]any{"session_id": session.ID})
+	app.cookies.clearRefreshToken(e)
+
 	// Invalidate Permissions and Roles cache when user logs out.
-	// For simplicity, invalidate all cached permissions and roles for the user regardless of the scope
 	app.models.Permissions.InvalidateAllForUserCache(ctx, session.User.ID)
 	app.models.Roles.InvalidateAllForUserCache(ctx, session.User.ID)
 
 	return e.NoContent(http.StatusOK)
 }
 
+// reauthenticate has the user prove their password, and their second factor if they have
+// one, again in the current session. It keeps operations guarded by requireRecentAuth
+// available for a while without logging in again. Failed attempts count towards the
+// lockout like failed logins do.
+func (app *application) reauthenticate(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var input struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	v := validator.New()
+
+	v.Check(validator.NotBlank(input.Password), "password", i18n.LocalizeMessage(localizer, "PasswordRequired", nil))
+
+	if !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	session := app.contextGetSession(e)
+
+	if session.APIKey != nil {
+		return app.notPermittedResponse(e)
+	}
+	if session.ID == 0 {
+		return app.invalidAuthenticationTokenResponse(e)
+	}
+
+	user, err := app.currentUser(ctx, e)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	err = app.verifyCurrentPassword(ctx, e, user, input.Password)
+	if err != nil {
+		return err
+	}
+
+	mfaEnabled, err := app.models.TwoFactor.IsEnabledForUser(ctx, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	if mfaEnabled {
+		if input.RecoveryCode == "" {
+			if data.ValidateTwoFactorCode(v, localizer, input.Code); !v.Valid() {
+				return app.failedValidationResponse(e, v.Errors)
+			}
+		}
+
+		tf, err := app.models.TwoFactor.GetForUser(ctx, user.ID)
+		if err != nil {
+			return app.serverErrorResponse(e, err)
+		}
+
+		err = app.verifyTwoFactor(ctx, e, user, tf, input.Code, input.RecoveryCode)
+		if err != nil {
+			return err
+		}
+	}
+
+	authenticatedAt, err := app.models.Sessions.Reauthenticate(ctx, session.ID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.invalidAuthenticationTokenResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	app.audit(ctx, e, data.AuditReauthenticated, user.ID, user.ID, map[string]any{"session_id": session.ID})
+
+	return e.JSON(http.StatusOK, echo.Map{"authenticated_at": authenticatedAt})
+}
+
 func (app *application) createActivationToken(e echo.Context) error {
 	localizer := app.contextGetLocalizer(e)
 
@@ -266,15 +501,12 @@ func (app *application) createPasswordResetToken(e echo.Context) error {
 		return app.serverErrorResponse(e, err)
 	}
 
-	app.background(func() {
-		data := map[string]any{
-			"passwordResetToken": token.Plaintext,
-		}
+	app.audit(ctx, e, data.AuditPasswordResetSent, 0, user.ID, nil)
 
-		err = app.mailer.Send(user.Email, "password_reset_token.tmpl", data)
-		if err != nil {
-			app.logger.Error(err.Error())
-		}
+	// The reset email doubles as the notice that a reset was requested. It isn't throttled,
+	// since it carries the token the user needs.
+	app.sendSecurityEmail(e, user, "password_reset_token.tmpl", map[string]any{
+		"passwordResetToken": token.Plaintext,
 	})
 
 	message := i18n.LocalizeMessage(localizer, "PasswordResetTokenCreated", nil)
@@ -289,20 +521,25 @@ func (app *application) refreshAuthenticationToken(e echo.Context) error {
 	defer cancel()
 
 	// Get refresh token from cookie
-	refreshTokenCookie, err := e.Cookie("refresh_token")
+	refreshTokenCookie, err := e.Cookie(refreshTokenCookieName)
 	if err != nil {
 		return app.invalidCredentialsResponse(e)
 	}
 
 	refreshToken := refreshTokenCookie.Value
 
+	// The cookie is sent by the browser whoever makes the request, so it only counts
+	// together with the CSRF token, which only the client it was issued to can read.
+	if !checkCSRFToken(e, refreshToken) {
+		return app.invalidCSRFTokenResponse(e)
+	}
+
 	v := validator.New()
 	if data.ValidateTokenPlaintext(v, localizer, refreshToken); !v.Valid() {
 		return app.failedValidationResponse(e, v.Errors)
 	}
 
-	// Get the user associated with the refresh token
-	user, err := app.models.Users.GetForToken(ctx, data.ScopeRefresh, refreshToken)
+	token, err := app.models.Tokens.GetByPlaintext(ctx, data.ScopeRefresh, refreshToken)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -312,21 +549,62 @@ func (app *application) refreshAuthenticationToken(e echo.Context) error {
 		}
 	}
 
-	// Implement refresh token rotation by deleting the current refresh token
-	// and assigning a new one.
-	refreshTokenHash := sha256.Sum256([]byte(refreshToken))
-	err = app.models.Tokens.DeleteByHash(ctx, refreshTokenHash[:])
+	// A refresh token that has already been rotated out is being replayed, which means
+	// it has most likely been stolen. Revoke the whole family so that neither the
+	// attacker nor the legitimate client can keep using any token derived from it.
+	if token.RotatedAt.Valid {
+		return app.handleRefreshTokenReuse(e, token)
+	}
+
+	// Implement refresh token rotation by marking the current refresh token as rotated
+	// and assigning a new one from the same family.
+	err = app.models.Tokens.MarkRotated(ctx, token.Hash)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			// Another request rotated the token between our read and write.
+			return app.handleRefreshTokenReuse(e, token)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	user, err := app.models.Users.GetByID(ctx, token.UserID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	if user.Disabled {
+		app.cookies.clearRefreshToken(e)
+		return app.accountDisabledResponse(e)
+	}
+
+	// Refresh tokens issued before token families existed start a new family on their first rotation.
+	if token.Family == nil {
+		token.Family = data.NewTokenFamily()
+	}
+
+	userAgent := e.Request().UserAgent()
+	session := &data.Session{ID: token.SessionID.Int64, UserID: user.ID}
+	if token.SessionID.Valid {
+		err = app.models.Sessions.Touch(ctx, session.ID, userAgent, e.RealIP())
+	} else {
+		// Likewise, refresh tokens issued before device sessions existed get a session now.
+		// When the user logged in is unknown, so the session counts as authenticated long ago
+		// and sensitive operations ask them to reauthenticate.
+		session, err = app.models.Sessions.New(ctx, user.ID, deviceNameFromUserAgent(userAgent), userAgent, e.RealIP(), time.Unix(0, 0))
+	}
 	if err != nil {
 		return app.serverErrorResponse(e, err)
 	}
 
-	newRefreshToken, err := app.models.Tokens.New(ctx, user.ID, refreshTokenTTL, data.ScopeRefresh)
+	newRefreshToken, err := app.models.Tokens.NewForSession(ctx, session, refreshTokenTTL, data.ScopeRefresh, token.Family)
 	if err != nil {
 		return app.serverErrorResponse(e, err)
 	}
 
 	// Create a new authentication token
-	authToken, err := app.models.Tokens.New(ctx, user.ID, authTokenTTL, data.ScopeAuthentication)
+	authToken, err := app.newAuthenticationToken(ctx, session, user, token.Family)
 	if err != nil {
 		return app.serverErrorResponse(e, err)
 	}
@@ -344,15 +622,7 @@ func (app *application) refreshAuthenticationToken(e echo.Context) error {
 		}
 	}
 
-	e.SetCookie(&http.Cookie{
-		Name:     "refresh_token",
-		Value:    newRefreshToken.Plaintext,
-		Expires:  newRefreshToken.Expiry.Time,
-		Path:     "/v1/",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
-	})
+	app.cookies.setRefreshToken(e, newRefreshToken)
 
 	return e.JSON(http.StatusCreated, echo.Map{
 		"authentication_token": authToken,
@@ -360,6 +630,39 @@ func (app *application) refreshAuthenticationToken(e echo.Context) error {
 	})
 }
 
+// handleRefreshTokenReuse revokes every token derived from a replayed refresh token. It uses a
+// fresh context so that the revocation completes even if the client aborts the request.
+func (app *application) handleRefreshTokenReuse(e echo.Context, token *data.Token) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	app.logger.Warn("refresh token reuse detected, revoking token family", "user_id", token.UserID, "session_id", token.SessionID.Int64, "ip", e.RealIP())
+
+	var err error
+	switch {
+	case token.SessionID.Valid:
+		err = app.models.Sessions.Delete(ctx, token.SessionID.Int64, token.UserID)
+		if errors.Is(err, data.ErrRecordNotFound) {
+			err = nil
+		}
+	case token.Family != nil:
+		err = app.models.Tokens.DeleteFamily(ctx, token.Family)
+	default:
+		// Tokens issued before families were introduced can't be traced back to a chain,
+		// so fall back to revoking every session of the user.
+		err = app.models.Tokens.DeleteAllForUser(ctx, data.ScopeRefresh, token.UserID)
+		if err == nil {
+			err = app.models.Tokens.DeleteAllForUser(ctx, data.ScopeAuthentication, token.UserID)
+		}
+	}
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.cookies.clearRefreshToken(e)
+	return app.invalidCredentialsResponse(e)
+}
+
 func (app *application) CleanupTokens() {
 	for {
 		select {
@@ -374,6 +677,16 @@ func (app *application) CleanupTokens() {
 			if err != nil {
 				app.logInternalError("app.models.Tokens.CleanupTokens failed", err)
 			}
+
+			err = app.models.Sessions.DeleteOrphaned(ctx, time.Hour)
+			if err != nil {
+				app.logInternalError("app.models.Sessions.DeleteOrphaned failed", err)
+			}
+
+			err = app.models.Logins.DeleteStale(ctx, app.config.lockout.window)
+			if err != nil {
+				app.logInternalError("app.models.Logins.DeleteStale failed", err)
+			}
 			timer := time.NewTimer(time.Until(startTime.Add(app.config.cleanup.tokensCleanupPeriod)))
 			select {
 			case <-app.serverCtx.Done():
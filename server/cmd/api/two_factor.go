@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/totp"
+	"adcentra.ai/internal/validator"
+	"github.com/labstack/echo/v4"
+)
+
+const totpIssuer = "Adcentra"
+
+func (app *application) enrollTwoFactor(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	session := app.contextGetSession(e)
+
+	enabled, err := app.models.TwoFactor.IsEnabledForUser(ctx, session.User.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	if enabled {
+		return app.twoFactorAlreadyEnabledResponse(e)
+	}
+
+	tf, err := app.models.TwoFactor.Enroll(ctx, session.User.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.JSON(http.StatusCreated, echo.Map{
+		"secret":           totp.EncodeSecret(tf.Secret),
+		"provisioning_uri": totp.ProvisioningURI(totpIssuer, session.User.Email, tf.Secret),
+	})
+}
+
+func (app *application) enableTwoFactor(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	v := validator.New()
+
+	if data.ValidateTwoFactorCode(v, localizer, input.Code); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	user, err := app.currentUser(ctx, e)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	tf, err := app.models.TwoFactor.GetForUser(ctx, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.twoFactorNotEnabledResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	if tf.Enabled() {
+		return app.twoFactorAlreadyEnabledResponse(e)
+	}
+
+	err = app.models.TwoFactor.Verify(ctx, tf, input.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidTwoFactorCode):
+			v.AddError("code", i18n.LocalizeMessage(localizer, "InvalidTwoFactorCode", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	recoveryCodes, err := app.models.TwoFactor.Enable(ctx, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.audit(ctx, e, data.AuditTwoFactorEnabled, user.ID, user.ID, nil)
+	app.notify(e, user, "two_factor_enabled.tmpl", nil)
+
+	return e.JSON(http.StatusOK, echo.Map{
+		"message":        i18n.LocalizeMessage(localizer, "TwoFactorEnabled", nil),
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+func (app *application) regenerateRecoveryCodes(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	v := validator.New()
+
+	if data.ValidateTwoFactorCode(v, localizer, input.Code); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	session := app.contextGetSession(e)
+
+	tf, err := app.models.TwoFactor.GetForUser(ctx, session.User.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.twoFactorNotEnabledResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	if !tf.Enabled() {
+		return app.twoFactorNotEnabledResponse(e)
+	}
+
+	err = app.models.TwoFactor.Verify(ctx, tf, input.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidTwoFactorCode):
+			v.AddError("code", i18n.LocalizeMessage(localizer, "InvalidTwoFactorCode", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	recoveryCodes, err := app.models.TwoFactor.RegenerateRecoveryCodes(ctx, session.User.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	return e.JSON(http.StatusOK, echo.Map{
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// disableTwoFactor turns two-factor authentication off. Besides a recent authentication it
+// takes the password and a current code or recovery code, so that neither a stolen token nor
+// a stolen password alone is enough to remove the second factor.
+func (app *application) disableTwoFactor(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var input struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	v := validator.New()
+
+	v.Check(validator.NotBlank(input.Password), "password", i18n.LocalizeMessage(localizer, "PasswordRequired", nil))
+	if input.RecoveryCode == "" {
+		data.ValidateTwoFactorCode(v, localizer, input.Code)
+	}
+
+	if !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	user, err := app.currentUser(ctx, e)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	tf, err := app.models.TwoFactor.GetForUser(ctx, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.twoFactorNotEnabledResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	if !tf.Enabled() {
+		return app.twoFactorNotEnabledResponse(e)
+	}
+
+	err = app.verifyCurrentPassword(ctx, e, user, input.Password)
+	if err != nil {
+		return err
+	}
+
+	err = app.verifyTwoFactor(ctx, e, user, tf, input.Code, input.RecoveryCode)
+	if err != nil {
+		return err
+	}
+
+	err = app.models.TwoFactor.Disable(ctx, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.audit(ctx, e, data.AuditTwoFactorDisabled, user.ID, user.ID, nil)
+	app.notify(e, user, "two_factor_disabled.tmpl", nil)
+
+	return e.JSON(http.StatusOK, echo.Map{
+		"message": i18n.LocalizeMessage(localizer, "TwoFactorDisabled", nil),
+	})
+}
+
+// verifyTwoFactor checks the code, or else the recovery code, a signed-in user gives to
+// confirm a sensitive operation. On failure the returned error is the response to send.
+func (app *application) verifyTwoFactor(ctx context.Context, e echo.Context, user *data.User, tf *data.TwoFactor, code, recoveryCode string) error {
+	var err error
+	if recoveryCode != "" {
+		err = app.models.TwoFactor.UseRecoveryCode(ctx, user.ID, recoveryCode)
+	} else {
+		err = app.models.TwoFactor.Verify(ctx, tf, code)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidTwoFactorCode):
+			app.audit(ctx, e, data.AuditLoginFailed, user.ID, user.ID, map[string]any{"reason": "two_factor"})
+			return app.invalidTwoFactorCodeResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	return nil
+}
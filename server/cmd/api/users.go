@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"adcentra.ai/internal/data"
 	"adcentra.ai/internal/i18n"
 	"adcentra.ai/internal/validator"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo/v4"
 )
 
@@ -33,7 +35,7 @@ func (app *application) registerUser(e echo.Context) error {
 		Activated: false,
 	}
 
-	if err := user.Password.Set(input.Password); err != nil {
+	if err := app.models.Users.SetPassword(user, input.Password); err != nil {
 		return err
 	}
 
@@ -43,6 +45,10 @@ func (app *application) registerUser(e echo.Context) error {
 		return app.failedValidationResponse(e, v.Errors)
 	}
 
+	if app.validatePasswordNotBreached(ctx, v, localizer, input.Password); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
 	err := app.models.Users.Insert(ctx, user)
 	if err != nil {
 		switch {
@@ -54,6 +60,8 @@ func (app *application) registerUser(e echo.Context) error {
 		}
 	}
 
+	app.audit(ctx, e, data.AuditUserRegistered, user.ID, user.ID, nil)
+
 	token, err := app.models.Tokens.New(ctx, user.ID, 3*24*time.Hour, data.ScopeActivation)
 	if err != nil {
 		return app.serverErrorResponse(e, err)
@@ -74,18 +82,113 @@ func (app *application) registerUser(e echo.Context) error {
 	})
 
 	// Log the user in
-	refreshToken, err := app.models.Tokens.New(ctx, user.ID, refreshTokenTTL, data.ScopeRefresh)
+	return app.completeLogin(ctx, e, user, "")
+}
+
+func (app *application) activateUser(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	var input struct {
+		TokenPlainText string `json:"token"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, localizer, input.TokenPlainText); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	user, err := app.models.Users.GetForToken(ctx, data.ScopeActivation, input.TokenPlainText)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", i18n.LocalizeMessage(localizer, "InvalidToken", nil))
+			return app.failedValidationResponse(e, v.Errors)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	user.Activated = true
+
+	err = app.models.Users.Update(ctx, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return app.editConflictResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(ctx, data.ScopeActivation, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.audit(ctx, e, data.AuditUserActivated, user.ID, user.ID, nil)
+
+	return e.NoContent(http.StatusOK)
+}
+
+func (app *application) getCurrentUser(e echo.Context) error {
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	user, err := app.currentUser(ctx, e)
 	if err != nil {
 		return app.serverErrorResponse(e, err)
 	}
 
-	authToken, err := app.models.Tokens.New(ctx, user.ID, authTokenTTL, data.ScopeAuthentication)
+	e.Response().Header().Set("ETag", versionETag(user.Version))
+	return e.JSON(http.StatusOK, echo.Map{
+		"user": user,
+	})
+}
+
+func (app *application) updateCurrentUser(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var input struct {
+		FullName        *string `json:"full_name"`
+		ProfileImageURL *string `json:"profile_image_url"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	user, err := app.currentUser(ctx, e)
 	if err != nil {
 		return app.serverErrorResponse(e, err)
 	}
 
-	user.LastLoginAt.Time = time.Now()
-	user.LastLoginAt.Valid = true
+	if !ifMatch(e, versionETag(user.Version)) {
+		return app.preconditionFailedResponse(e)
+	}
+
+	if input.FullName != nil {
+		user.FullName = *input.FullName
+	}
+	if input.ProfileImageURL != nil {
+		// An empty URL removes the profile image.
+		user.ProfileImageURL = pgtype.Text{String: *input.ProfileImageURL, Valid: *input.ProfileImageURL != ""}
+	}
+
+	v := validator.New()
+
+	if data.ValidateUser(v, localizer, user); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
 	err = app.models.Users.Update(ctx, user)
 	if err != nil {
 		switch {
@@ -96,29 +199,100 @@ func (app *application) registerUser(e echo.Context) error {
 		}
 	}
 
-	e.SetCookie(&http.Cookie{
-		Name:     "refresh_token",
-		Value:    refreshToken.Plaintext,
-		Expires:  refreshToken.Expiry.Time,
-		Path:     "/v1/",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
+	e.Response().Header().Set("ETag", versionETag(user.Version))
+	return e.JSON(http.StatusOK, echo.Map{
+		"user": user,
 	})
+}
+
+func (app *application) changeCurrentUserPassword(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	// Changing the password signs out every other session, which only makes sense from one.
+	session := app.contextGetSession(e)
+	if session.APIKey != nil {
+		return app.notPermittedResponse(e)
+	}
+
+	var input struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	user, err := app.currentUser(ctx, e)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
 
-	return e.JSON(http.StatusCreated, echo.Map{
-		"authentication_token": authToken,
-		"user":                 user,
+	v := validator.New()
+
+	v.Check(validator.NotBlank(input.CurrentPassword), "current_password", i18n.LocalizeMessage(localizer, "PasswordRequired", nil))
+	data.ValidatePasswordPlaintext(v, localizer, input.NewPassword, app.models.Users.MaxPasswordBytes(), user.Email, user.FullName)
+
+	if !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	if app.validatePasswordNotBreached(ctx, v, localizer, input.NewPassword); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	err = app.verifyCurrentPassword(ctx, e, user, input.CurrentPassword)
+	if err != nil {
+		return err
+	}
+
+	err = app.models.Users.SetPassword(user, input.NewPassword)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	err = app.models.Users.Update(ctx, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return app.editConflictResponse(e)
+		default:
+			return app.serverErrorResponse(e, err)
+		}
+	}
+
+	// Sign out every other device, so that anyone who knew the old password loses access.
+	// Tokens from before device sessions existed have no session to keep, so a caller using
+	// one is signed out as well and logs in again with the new password.
+	err = app.models.Sessions.DeleteAllForUserExcept(ctx, user.ID, session.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(ctx, data.ScopePasswordReset, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.audit(ctx, e, data.AuditPasswordChanged, user.ID, user.ID, nil)
+	app.notify(e, user, "password_changed.tmpl", nil)
+
+	message := i18n.LocalizeMessage(localizer, "PasswordChanged", nil)
+	return e.JSON(http.StatusOK, echo.Map{
+		"message": message,
 	})
 }
 
-func (app *application) activateUser(e echo.Context) error {
+func (app *application) updateUserPassword(e echo.Context) error {
 	localizer := app.contextGetLocalizer(e)
 	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
 	defer cancel()
 
 	var input struct {
 		TokenPlainText string `json:"token"`
+		Password       string `json:"password"`
 	}
 
 	if err := e.Bind(&input); err != nil {
@@ -131,7 +305,7 @@ func (app *application) activateUser(e echo.Context) error {
 		return app.failedValidationResponse(e, v.Errors)
 	}
 
-	user, err := app.models.Users.GetForToken(ctx, data.ScopeActivation, input.TokenPlainText)
+	user, err := app.models.Users.GetForToken(ctx, data.ScopePasswordReset, input.TokenPlainText)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -142,7 +316,20 @@ func (app *application) activateUser(e echo.Context) error {
 		}
 	}
 
-	user.Activated = true
+	// The password is checked once the user is known, so that it can be compared with
+	// their email address and name.
+	if data.ValidatePasswordPlaintext(v, localizer, input.Password, app.models.Users.MaxPasswordBytes(), user.Email, user.FullName); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	if app.validatePasswordNotBreached(ctx, v, localizer, input.Password); !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	err = app.models.Users.SetPassword(user, input.Password)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
 
 	err = app.models.Users.Update(ctx, user)
 	if err != nil {
@@ -154,30 +341,100 @@ func (app *application) activateUser(e echo.Context) error {
 		}
 	}
 
-	err = app.models.Tokens.DeleteAllForUser(ctx, data.ScopeActivation, user.ID)
+	err = app.models.Tokens.DeleteAllForUser(ctx, data.ScopePasswordReset, user.ID)
 	if err != nil {
 		return app.serverErrorResponse(e, err)
 	}
 
+	app.audit(ctx, e, data.AuditPasswordReset, user.ID, user.ID, nil)
+	app.notify(e, user, "password_changed.tmpl", nil)
+
 	return e.NoContent(http.StatusOK)
 }
 
-func (app *application) getCurrentUser(e echo.Context) error {
-	session := app.contextGetSession(e)
+func (app *application) requestEmailChange(e echo.Context) error {
+	localizer := app.contextGetLocalizer(e)
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
+	defer cancel()
 
-	return e.JSON(http.StatusOK, echo.Map{
-		"user": session.User,
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	if err := e.Bind(&input); err != nil {
+		return app.badRequestResponse(e, err)
+	}
+
+	v := validator.New()
+
+	data.ValidateEmail(v, localizer, input.Email)
+	v.Check(validator.NotBlank(input.Password), "password", i18n.LocalizeMessage(localizer, "PasswordRequired", nil))
+
+	if !v.Valid() {
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	user, err := app.currentUser(ctx, e)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	err = app.verifyCurrentPassword(ctx, e, user, input.Password)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(input.Email, user.Email) {
+		v.AddError("email", i18n.LocalizeMessage(localizer, "EmailMustBeDifferent", nil))
+		return app.failedValidationResponse(e, v.Errors)
+	}
+
+	_, err = app.models.Users.GetByEmail(ctx, input.Email)
+	switch {
+	case err == nil:
+		v.AddError("email", i18n.LocalizeMessage(localizer, "UserAlreadyExists", nil))
+		return app.failedValidationResponse(e, v.Errors)
+	case !errors.Is(err, data.ErrRecordNotFound):
+		return app.serverErrorResponse(e, err)
+	}
+
+	// Only the latest requested address can be confirmed.
+	err = app.models.Tokens.DeleteAllForUser(ctx, data.ScopeEmailChange, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	token, err := app.models.Tokens.NewForEmailChange(ctx, user.ID, emailChangeTokenTTL, input.Email)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	// The token goes to the new address, and a notice to the current one in case the request
+	// wasn't the owner's doing. Neither is throttled, since every request needs its token.
+	recipient := *user
+	recipient.Email = input.Email
+	app.sendSecurityEmail(e, &recipient, "email_change.tmpl", map[string]any{
+		"newEmail":         input.Email,
+		"emailChangeToken": token.Plaintext,
+	})
+	app.sendSecurityEmail(e, user, "email_change_notice.tmpl", map[string]any{
+		"newEmail": input.Email,
+	})
+
+	message := i18n.LocalizeMessage(localizer, "EmailChangeTokenCreated", nil)
+	return e.JSON(http.StatusAccepted, echo.Map{
+		"message": message,
 	})
 }
 
-func (app *application) updateUserPassword(e echo.Context) error {
+func (app *application) confirmEmailChange(e echo.Context) error {
 	localizer := app.contextGetLocalizer(e)
 	ctx, cancel := context.WithTimeout(e.Request().Context(), 10*time.Second)
 	defer cancel()
 
 	var input struct {
 		TokenPlainText string `json:"token"`
-		Password       string `json:"password"`
 	}
 
 	if err := e.Bind(&input); err != nil {
@@ -186,14 +443,11 @@ func (app *application) updateUserPassword(e echo.Context) error {
 
 	v := validator.New()
 
-	data.ValidateTokenPlaintext(v, localizer, input.TokenPlainText)
-	data.ValidatePasswordPlaintext(v, localizer, input.Password)
-
-	if !v.Valid() {
+	if data.ValidateTokenPlaintext(v, localizer, input.TokenPlainText); !v.Valid() {
 		return app.failedValidationResponse(e, v.Errors)
 	}
 
-	user, err := app.models.Users.GetForToken(ctx, data.ScopePasswordReset, input.TokenPlainText)
+	token, err := app.models.Tokens.GetByPlaintext(ctx, data.ScopeEmailChange, input.TokenPlainText)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -204,14 +458,22 @@ func (app *application) updateUserPassword(e echo.Context) error {
 		}
 	}
 
-	err = user.Password.Set(input.Password)
+	user, err := app.models.Users.GetByID(ctx, token.UserID)
 	if err != nil {
 		return app.serverErrorResponse(e, err)
 	}
 
+	previousEmail := user.Email
+	user.Email = token.Email.String
+	// The new address has just been proven, same as with an activation token.
+	user.Activated = true
+
 	err = app.models.Users.Update(ctx, user)
 	if err != nil {
 		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", i18n.LocalizeMessage(localizer, "UserAlreadyExists", nil))
+			return app.failedValidationResponse(e, v.Errors)
 		case errors.Is(err, data.ErrEditConflict):
 			return app.editConflictResponse(e)
 		default:
@@ -219,10 +481,42 @@ func (app *application) updateUserPassword(e echo.Context) error {
 		}
 	}
 
-	err = app.models.Tokens.DeleteAllForUser(ctx, data.ScopePasswordReset, user.ID)
+	err = app.models.Tokens.DeleteAllForUser(ctx, data.ScopeEmailChange, user.ID)
 	if err != nil {
 		return app.serverErrorResponse(e, err)
 	}
 
-	return e.NoContent(http.StatusOK)
+	// Sign the user out everywhere else. If the confirmation comes from one of the user's
+	// own sessions, that session is kept.
+	session := app.contextGetSession(e)
+	var keepSessionID int64
+	if !session.User.IsAnonymous() && session.UserID == user.ID {
+		keepSessionID = session.ID
+	}
+
+	err = app.models.Sessions.DeleteAllForUserExcept(ctx, user.ID, keepSessionID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	// API keys outlive sessions and would keep whoever requested the change in, so they
+	// have to be issued anew.
+	err = app.models.APIKeys.DeleteAllForUser(ctx, user.ID)
+	if err != nil {
+		return app.serverErrorResponse(e, err)
+	}
+
+	app.audit(ctx, e, data.AuditEmailChanged, user.ID, user.ID, map[string]any{
+		"previous_email": previousEmail,
+		"email":          user.Email,
+	})
+
+	// The notice goes to the previous address, in case the change wasn't the owner's doing.
+	previous := *user
+	previous.Email = previousEmail
+	app.notify(e, &previous, "email_changed.tmpl", map[string]any{"newEmail": user.Email})
+
+	return e.JSON(http.StatusOK, echo.Map{
+		"user": user,
+	})
 }
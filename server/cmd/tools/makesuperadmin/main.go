@@ -32,7 +32,7 @@ func main() {
 	}
 	defer pool.Close()
 
-	models := data.NewModels(pool, nil)
+	models := data.NewModels(pool, nil, nil)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -66,6 +66,8 @@ func main() {
 			log.Fatalf("Error: %s", err)
 		}
 
+		audit(ctx, models, data.AuditRoleRevoked, user.ID)
+
 		fmt.Printf("Successfully revoked superadmin privileges from user: %s\n", *email)
 	} else {
 		err = models.Roles.AddForUser(ctx, user.ID, data.Roles{data.RoleSuperAdmin})
@@ -76,10 +78,25 @@ func main() {
 			log.Fatalf("Error: %s", err)
 		}
 
+		audit(ctx, models, data.AuditRoleGranted, user.ID)
+
 		fmt.Printf("Successfully granted superadmin privileges to user: %s\n", *email)
 	}
 }
 
+// audit records the change. It is made from the command line, so there is no actor.
+func audit(ctx context.Context, models data.Models, action data.AuditAction, userID int64) {
+	err := models.Audit.Insert(ctx, &data.AuditEvent{
+		TargetID:  data.AuditUserID(userID),
+		Action:    action,
+		UserAgent: "makesuperadmin",
+		Metadata:  map[string]any{"role": data.RoleSuperAdmin, "source": "cli"},
+	})
+	if err != nil {
+		log.Printf("Warning: failed to record audit event: %s", err)
+	}
+}
+
 func openDB(dsn string) (*pgxpool.Pool, error) {
 	poolConfig, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
@@ -12,32 +12,43 @@ import (
 )
 
 var (
-	defaultPermissions = data.Permissions{
-		data.PermDebugVarsView,
-		data.PermAdminsView,
-		data.PermAdminsManage,
-		data.PermUsersView,
-		data.PermUsersManage,
-		data.PermRolesView,
-		data.PermRolesManage,
-	}
-
 	defaultRoles = []data.Role{
 		data.RoleSuperAdmin,
 		data.RoleAdmin,
 		data.RoleUser,
+		data.RoleOrgOwner,
+		data.RoleOrgAdmin,
+		data.RoleOrgMember,
 	}
 
 	defaultRolePermissions = map[data.Role]data.Permissions{
+		// Admins can see other admins but only superadmins can appoint them, unless
+		// admins:manage is granted explicitly.
 		data.RoleAdmin: {
 			data.PermAdminsView,
-			data.PermAdminsManage,
 			data.PermUsersView,
 			data.PermUsersManage,
 			data.PermRolesView,
 			data.PermRolesManage,
+			data.PermAuditView,
 		},
 		data.RoleUser: {},
+		// Organization roles only take effect inside the organization they are held in.
+		data.RoleOrgOwner: {
+			data.PermOrgView,
+			data.PermOrgManage,
+			data.PermMembersView,
+			data.PermMembersManage,
+		},
+		data.RoleOrgAdmin: {
+			data.PermOrgView,
+			data.PermMembersView,
+			data.PermMembersManage,
+		},
+		data.RoleOrgMember: {
+			data.PermOrgView,
+			data.PermMembersView,
+		},
 	}
 )
 
@@ -45,7 +56,7 @@ func main() {
 	var (
 		dsn             = flag.String("dsn", os.Getenv("DB_DSN"), "PostgreSQL connection string")
 		roles           = flag.Bool("roles", false, "Flag to update roles")
-		permissions     = flag.Bool("permissions", false, "Flag to update permissions")
+		permissions     = flag.Bool("permissions", false, "Flag to sync permissions with the catalog")
 		rolePermissions = flag.Bool("role-permissions", false, "Flag to update role permissions mapping")
 		all             = flag.Bool("all", false, "Flag to update all defaults")
 	)
@@ -62,17 +73,19 @@ func main() {
 	}
 	defer pool.Close()
 
-	models := data.NewModels(pool, nil)
+	models := data.NewModels(pool, nil, nil)
 
 	if *permissions || *all {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		err = models.Permissions.Create(ctx, defaultPermissions)
+		// The catalog is declared in the data package; permissions no longer in it are
+		// removed from the database along with their grants.
+		err = models.Permissions.Sync(ctx, data.PermissionCatalog())
 		if err != nil {
 			log.Fatal(err)
 		}
-		log.Println("Permissions updated")
+		log.Println("Permissions synced")
 	}
 
 	if *roles || *all {
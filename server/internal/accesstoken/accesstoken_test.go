@@ -0,0 +1,354 @@
+package accesstoken_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"adcentra.ai/internal/accesstoken"
+)
+
+var b64 = base64.RawURLEncoding
+
+// signRaw signs any header and claims with the key, so that tokens the package would never
+// issue can be made.
+func signRaw(t *testing.T, key accesstoken.Key, header, claims any) string {
+	t.Helper()
+
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingInput := b64.EncodeToString(h) + "." + b64.EncodeToString(c)
+	return signingInput + "." + b64.EncodeToString(ed25519.Sign(key.PrivateKey, []byte(signingInput)))
+}
+
+func testClaims(now time.Time) accesstoken.Claims {
+	return accesstoken.Claims{
+		Subject:     "42",
+		SessionID:   7,
+		Email:       "jane@example.com",
+		Activated:   true,
+		Roles:       []string{"user"},
+		Permissions: []string{"users:view"},
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   now.Add(time.Minute).Unix(),
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	key := accesstoken.GenerateKey("k1")
+	ks := accesstoken.NewKeySet(&key)
+	now := time.Now()
+
+	token, err := ks.Sign(testClaims(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !accesstoken.IsAccessToken(token) {
+		t.Errorf("IsAccessToken(%q) = false, want true", token)
+	}
+
+	claims, err := ks.Verify(token, now)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	want := testClaims(now)
+	want.Issuer = accesstoken.Issuer
+	got, _ := json.Marshal(claims)
+	wanted, _ := json.Marshal(want)
+	if string(got) != string(wanted) {
+		t.Errorf("Verify() = %s, want %s", got, wanted)
+	}
+
+	userID, err := claims.UserID()
+	if err != nil || userID != 42 {
+		t.Errorf("UserID() = %d, %v, want 42", userID, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	key := accesstoken.GenerateKey("k1")
+	other := accesstoken.GenerateKey("k1")
+	ks := accesstoken.NewKeySet(&key)
+	now := time.Now()
+
+	claims := testClaims(now)
+	claims.Issuer = accesstoken.Issuer
+	header := map[string]string{"alg": "EdDSA", "typ": "JWT", "kid": "k1"}
+
+	valid, err := ks.Sign(testClaims(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, ".")
+
+	escalated := claims
+	escalated.Roles = []string{"superadmin"}
+	escalatedPayload, _ := json.Marshal(escalated)
+
+	signature, _ := b64.DecodeString(parts[2])
+	signature[0] ^= 0xff
+
+	wrongIssuer := claims
+	wrongIssuer.Issuer = "someone-else"
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			name:    "tampered payload",
+			token:   parts[0] + "." + b64.EncodeToString(escalatedPayload) + "." + parts[2],
+			wantErr: accesstoken.ErrInvalidToken,
+		},
+		{
+			name:    "tampered signature",
+			token:   parts[0] + "." + parts[1] + "." + b64.EncodeToString(signature),
+			wantErr: accesstoken.ErrInvalidToken,
+		},
+		{
+			name:    "signed by another key with the same kid",
+			token:   signRaw(t, other, header, claims),
+			wantErr: accesstoken.ErrInvalidToken,
+		},
+		{
+			name:    "alg none",
+			token:   b64.EncodeToString([]byte(`{"alg":"none","typ":"JWT","kid":"k1"}`)) + "." + parts[1] + ".",
+			wantErr: accesstoken.ErrInvalidToken,
+		},
+		{
+			name:    "alg HS256",
+			token:   signRaw(t, key, map[string]string{"alg": "HS256", "typ": "JWT", "kid": "k1"}, claims),
+			wantErr: accesstoken.ErrInvalidToken,
+		},
+		{
+			name:    "unknown kid",
+			token:   signRaw(t, key, map[string]string{"alg": "EdDSA", "typ": "JWT", "kid": "k2"}, claims),
+			wantErr: accesstoken.ErrUnknownKey,
+		},
+		{
+			name:    "wrong issuer",
+			token:   signRaw(t, key, header, wrongIssuer),
+			wantErr: accesstoken.ErrInvalidToken,
+		},
+		{
+			name:    "claims aren't JSON",
+			token:   signRaw(t, key, header, "not claims"),
+			wantErr: accesstoken.ErrInvalidToken,
+		},
+		{
+			name:    "empty",
+			token:   "",
+			wantErr: accesstoken.ErrInvalidToken,
+		},
+		{
+			name:    "two segments",
+			token:   parts[0] + "." + parts[1],
+			wantErr: accesstoken.ErrInvalidToken,
+		},
+		{
+			name:    "four segments",
+			token:   valid + "." + parts[2],
+			wantErr: accesstoken.ErrInvalidToken,
+		},
+		{
+			name:    "header isn't base64url",
+			token:   "!!!." + parts[1] + "." + parts[2],
+			wantErr: accesstoken.ErrInvalidToken,
+		},
+		{
+			name:    "header isn't JSON",
+			token:   b64.EncodeToString([]byte("EdDSA")) + "." + parts[1] + "." + parts[2],
+			wantErr: accesstoken.ErrInvalidToken,
+		},
+		{
+			name:    "signature isn't base64url",
+			token:   parts[0] + "." + parts[1] + ".!!!",
+			wantErr: accesstoken.ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ks.Verify(tt.token, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() = %v, %v, want error %v", claims, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyExpiry(t *testing.T) {
+	key := accesstoken.GenerateKey("k1")
+	ks := accesstoken.NewKeySet(&key)
+	now := time.Unix(1_700_000_000, 0)
+
+	token, err := ks.Sign(testClaims(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry := now.Add(time.Minute)
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		wantErr error
+	}{
+		{"just before expiry", expiry.Add(-time.Second), nil},
+		{"at expiry", expiry, accesstoken.ErrExpiredToken},
+		{"after expiry", expiry.Add(time.Second), accesstoken.ErrExpiredToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ks.Verify(token, tt.at)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := accesstoken.GenerateKey("old")
+	newKey := accesstoken.GenerateKey("new")
+	now := time.Now()
+
+	oldToken, err := accesstoken.NewKeySet(&oldKey).Sign(testClaims(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := accesstoken.NewKeySet(&newKey, oldKey)
+
+	if _, err := rotated.Verify(oldToken, now); err != nil {
+		t.Errorf("Verify() of a token signed with the retired key: %v", err)
+	}
+
+	newToken, err := rotated.Sign(testClaims(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var h struct {
+		KeyID string `json:"kid"`
+	}
+	raw, _ := b64.DecodeString(strings.Split(newToken, ".")[0])
+	if err := json.Unmarshal(raw, &h); err != nil || h.KeyID != "new" {
+		t.Errorf("Sign() kid = %q, want %q", h.KeyID, "new")
+	}
+
+	if _, err := accesstoken.NewKeySet(&oldKey).Verify(newToken, now); !errors.Is(err, accesstoken.ErrUnknownKey) {
+		t.Errorf("Verify() with only the retired key = %v, want %v", err, accesstoken.ErrUnknownKey)
+	}
+
+	// Without a signing key, tokens are still verified, but none are issued.
+	verifyOnly := accesstoken.NewKeySet(nil, oldKey, newKey)
+
+	if verifyOnly.CanSign() {
+		t.Error("CanSign() = true without a signing key")
+	}
+	if _, err := verifyOnly.Sign(testClaims(now)); !errors.Is(err, accesstoken.ErrNoSigningKey) {
+		t.Errorf("Sign() error = %v, want %v", err, accesstoken.ErrNoSigningKey)
+	}
+	for _, token := range []string{oldToken, newToken} {
+		if _, err := verifyOnly.Verify(token, now); err != nil {
+			t.Errorf("Verify() without a signing key: %v", err)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	key := accesstoken.GenerateKey("2024-01")
+
+	signing, err := accesstoken.ParseSigningKey(accesstoken.EncodeSigningKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signing.ID != key.ID || !signing.PrivateKey.Equal(key.PrivateKey) || !signing.PublicKey.Equal(key.PublicKey) {
+		t.Errorf("ParseSigningKey() = %+v, want %+v", signing, key)
+	}
+
+	verification, err := accesstoken.ParseVerificationKey(accesstoken.EncodeVerificationKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verification.ID != key.ID || !verification.PublicKey.Equal(key.PublicKey) || verification.PrivateKey != nil {
+		t.Errorf("ParseVerificationKey() = %+v, want the public key only", verification)
+	}
+
+	// Tokens signed with the parsed signing key verify with the parsed verification key.
+	now := time.Now()
+	token, err := accesstoken.NewKeySet(&signing).Sign(testClaims(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accesstoken.NewKeySet(nil, verification).Verify(token, now); err != nil {
+		t.Errorf("Verify() with the parsed verification key: %v", err)
+	}
+}
+
+func TestParseKeysInvalid(t *testing.T) {
+	seed := b64.EncodeToString(make([]byte, ed25519.SeedSize))
+	public := b64.EncodeToString(make([]byte, ed25519.PublicKeySize))
+
+	tests := []struct {
+		name  string
+		parse func(string) (accesstoken.Key, error)
+		key   string
+	}{
+		{"signing key without kid", accesstoken.ParseSigningKey, seed},
+		{"signing key with empty kid", accesstoken.ParseSigningKey, ":" + seed},
+		{"signing key isn't base64url", accesstoken.ParseSigningKey, "k1:" + strings.Repeat("!", 43)},
+		{"signing key too short", accesstoken.ParseSigningKey, "k1:" + b64.EncodeToString(make([]byte, ed25519.SeedSize-1))},
+		{"signing key is a private key", accesstoken.ParseSigningKey, "k1:" + b64.EncodeToString(make([]byte, ed25519.PrivateKeySize))},
+		{"verification key without kid", accesstoken.ParseVerificationKey, public},
+		{"verification key isn't base64url", accesstoken.ParseVerificationKey, "k1:" + strings.Repeat("!", 43)},
+		{"verification key too long", accesstoken.ParseVerificationKey, "k1:" + b64.EncodeToString(make([]byte, ed25519.PublicKeySize+1))},
+		{"empty", accesstoken.ParseVerificationKey, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.parse(tt.key); !errors.Is(err, accesstoken.ErrInvalidKey) {
+				t.Errorf("error = %v, want %v", err, accesstoken.ErrInvalidKey)
+			}
+		})
+	}
+}
+
+func TestIsAccessToken(t *testing.T) {
+	// Opaque tokens and API keys are made of these, and must never pass for signed tokens.
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"opaque token", rand.Text(), false},
+		{"API key", "adc_" + rand.Text()[:12] + "_" + rand.Text(), false},
+		{"three segments", "a.b.c", true},
+		{"two segments", "a.b", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := accesstoken.IsAccessToken(tt.token); got != tt.want {
+				t.Errorf("IsAccessToken(%q) = %t, want %t", tt.token, got, tt.want)
+			}
+		})
+	}
+}
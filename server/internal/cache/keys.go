@@ -2,11 +2,15 @@ package cache
 
 import (
 	"fmt"
+	"strings"
 )
 
 const (
-	KeyPermissionsPrefix = "permissions:"
-	KeyRolesPrefix       = "roles:"
+	KeyPermissionsPrefix       = "permissions:"
+	KeyRolesPrefix             = "roles:"
+	KeyLoginAttemptsPrefix     = "login_attempts:"
+	KeyUserStatusPrefix        = "user_status:"
+	KeyMagicLinkRequestsPrefix = "magic_link_requests:"
 )
 
 func GeneratePermissionsKey(userID int64) string {
@@ -16,3 +20,16 @@ func GeneratePermissionsKey(userID int64) string {
 func GenerateRolesKey(userID int64) string {
 	return fmt.Sprintf("%s%d", KeyRolesPrefix, userID)
 }
+
+// Emails are case-insensitive, so the key is built from the lower-cased address.
+func GenerateLoginAttemptsKey(email string) string {
+	return KeyLoginAttemptsPrefix + strings.ToLower(email)
+}
+
+func GenerateUserStatusKey(userID int64) string {
+	return fmt.Sprintf("%s%d", KeyUserStatusPrefix, userID)
+}
+
+func GenerateMagicLinkRequestsKey(email string) string {
+	return KeyMagicLinkRequestsPrefix + strings.ToLower(email)
+}
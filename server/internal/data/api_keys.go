@@ -0,0 +1,241 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"adcentra.ai/internal/db/sqlc"
+	i18n "adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/validator"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	// APIKeyPrefix marks API keys so that they can be told apart from other bearer tokens.
+	APIKeyPrefix = "adc_"
+
+	apiKeyIDLength = 12
+	// Last-used timestamps are only written once per interval to keep busy keys from
+	// updating their row on every request.
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrDuplicateAPIKeyName = errors.New("duplicate api key name")
+)
+
+// APIKey is a long-lived credential for programmatic access. The plaintext has the form
+// adc_<id>_<secret>; only the id part and a hash of the whole key are stored. A key with
+// nil Permissions acts with all of its owner's permissions, otherwise only with those
+// listed that the owner still has.
+type APIKey struct {
+	ID          int64              `json:"id"`
+	UserID      int64              `json:"-"`
+	Name        string             `json:"name"`
+	Prefix      string             `json:"prefix"`
+	Hash        []byte             `json:"-"`
+	Permissions Permissions        `json:"permissions"`
+	ExpiresAt   pgtype.Timestamptz `json:"expires_at"`
+	LastUsedAt  pgtype.Timestamptz `json:"last_used_at"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `json:"-"`
+	Plaintext   string             `json:"key,omitempty"`
+}
+
+func (key *APIKey) fromSQLCAPIKey(k sqlc.ApiKey) {
+	*key = APIKey{
+		ID:         k.ID,
+		UserID:     k.UserID,
+		Name:       k.Name,
+		Prefix:     APIKeyPrefix + k.Prefix,
+		Hash:       k.Hash,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		CreatedAt:  k.CreatedAt,
+		UpdatedAt:  k.UpdatedAt,
+	}
+	if k.Permissions != nil {
+		key.Permissions = Permissions{}
+		key.Permissions.FromStrings(k.Permissions)
+	}
+}
+
+// Scoped reports whether the key is limited to a subset of its owner's permissions.
+func (key *APIKey) Scoped() bool {
+	return key.Permissions != nil
+}
+
+// Restrict narrows the owner's permissions down to those granted to the key. Either side
+// may hold the broader grant, so codes are kept from both sides when the other grants them.
+func (key *APIKey) Restrict(permissions Permissions) Permissions {
+	if !key.Scoped() {
+		return permissions
+	}
+
+	restricted := Permissions{}
+	for _, code := range key.Permissions {
+		if permissions.Has(code) && !restricted.contains(code) {
+			restricted = append(restricted, code)
+		}
+	}
+	for _, code := range permissions {
+		if key.Permissions.Has(code) && !restricted.contains(code) {
+			restricted = append(restricted, code)
+		}
+	}
+	return restricted
+}
+
+// IsAPIKey reports whether the bearer token has the shape of an API key.
+func IsAPIKey(s string) bool {
+	return strings.HasPrefix(s, APIKeyPrefix)
+}
+
+func parseAPIKey(plaintext string) (string, bool) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(plaintext, APIKeyPrefix), "_")
+	if !ok || len(id) != apiKeyIDLength || secret == "" {
+		return "", false
+	}
+	return id, true
+}
+
+type APIKeyModel struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+}
+
+func (m APIKeyModel) New(ctx context.Context, userID int64, name string, permissions Permissions, expiresAt pgtype.Timestamptz) (*APIKey, error) {
+	id := rand.Text()[:apiKeyIDLength]
+	plaintext := APIKeyPrefix + id + "_" + rand.Text()
+	hash := sha256.Sum256([]byte(plaintext))
+
+	var perms []string
+	if permissions != nil {
+		perms = permissions.ToStrings()
+	}
+
+	k, err := m.queries.InsertAPIKey(ctx, sqlc.InsertAPIKeyParams{
+		UserID:      userID,
+		Name:        name,
+		Prefix:      id,
+		Hash:        hash[:],
+		Permissions: perms,
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			if pgErr.Code == strconv.Itoa(23505) && strings.Contains(pgErr.ConstraintName, "api_keys_user_id_name_key") {
+				return nil, ErrDuplicateAPIKeyName
+			}
+		}
+		return nil, err
+	}
+
+	var key APIKey
+	key.fromSQLCAPIKey(k)
+	key.Plaintext = plaintext
+	return &key, nil
+}
+
+// GetForPlaintext returns an unexpired API key together with its owner.
+func (m APIKeyModel) GetForPlaintext(ctx context.Context, plaintext string) (*APIKey, *User, error) {
+	id, ok := parseAPIKey(plaintext)
+	if !ok {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	row, err := m.queries.GetUserForAPIKey(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	hash := sha256.Sum256([]byte(plaintext))
+	if subtle.ConstantTimeCompare(hash[:], row.ApiKey.Hash) != 1 {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	var key APIKey
+	key.fromSQLCAPIKey(row.ApiKey)
+	var user User
+	user.fromSQLCUser(row.User)
+	return &key, &user, nil
+}
+
+func (m APIKeyModel) GetAllForUser(ctx context.Context, userID int64) ([]*APIKey, error) {
+	rows, err := m.queries.GetAllAPIKeysForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*APIKey, len(rows))
+	for i, k := range rows {
+		keys[i] = &APIKey{}
+		keys[i].fromSQLCAPIKey(k)
+	}
+	return keys, nil
+}
+
+// Touch records that the key has just been used.
+func (m APIKeyModel) Touch(ctx context.Context, id int64) error {
+	return m.queries.TouchAPIKey(ctx, sqlc.TouchAPIKeyParams{
+		ID:             id,
+		LastUsedBefore: pgtype.Timestamptz{Time: time.Now().Add(-apiKeyTouchInterval), Valid: true},
+	})
+}
+
+func (m APIKeyModel) Delete(ctx context.Context, id, userID int64) error {
+	result, err := m.queries.DeleteAPIKey(ctx, sqlc.DeleteAPIKeyParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteAllForUser revokes every API key of the user.
+func (m APIKeyModel) DeleteAllForUser(ctx context.Context, userID int64) error {
+	return m.queries.DeleteAllAPIKeysForUser(ctx, userID)
+}
+
+// ValidateAPIKey checks a new key. Keys can only be granted permissions that their owner
+// has, unless the owner is a superadmin.
+func ValidateAPIKey(v *validator.Validator, localizer *goi18n.Localizer, key *APIKey, ownerPermissions Permissions, ownerRoles Roles) {
+	v.Check(validator.NotBlank(key.Name), "name", i18n.LocalizeMessage(localizer, "APIKeyNameMustBeProvided", nil))
+	v.Check(validator.MaxChars(key.Name, 64), "name", i18n.LocalizeMessage(localizer, "APIKeyNameMustNotBeMoreThan64CharactersLong", nil))
+
+	if key.ExpiresAt.Valid {
+		v.Check(key.ExpiresAt.Time.After(time.Now()), "expires_at", i18n.LocalizeMessage(localizer, "APIKeyExpiryMustBeInTheFuture", nil))
+	}
+
+	if !ownerRoles.Has(RoleSuperAdmin) {
+		for _, code := range key.Permissions {
+			if !ownerPermissions.Has(code) {
+				v.AddError("permissions", i18n.LocalizeMessage(localizer, "APIKeyPermissionsMustBeHeldByUser", nil))
+				break
+			}
+		}
+	}
+}
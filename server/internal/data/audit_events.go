@@ -0,0 +1,176 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+
+	"adcentra.ai/internal/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AuditAction string
+
+const (
+	AuditLogin                  AuditAction = "login"
+	AuditLoginFailed            AuditAction = "login.failed"
+	AuditLogout                 AuditAction = "logout"
+	AuditSessionsRevoked        AuditAction = "sessions.revoked"
+	AuditUserRegistered         AuditAction = "user.registered"
+	AuditUserActivated          AuditAction = "user.activated"
+	AuditUserDeactivated        AuditAction = "user.deactivated"
+	AuditDeletionRequested      AuditAction = "user.deletion_requested"
+	AuditEmailChanged           AuditAction = "email.changed"
+	AuditPasswordChanged        AuditAction = "password.changed"
+	AuditPasswordReset          AuditAction = "password.reset"
+	AuditPasswordResetSent      AuditAction = "password.reset_requested"
+	AuditRoleGranted            AuditAction = "role.granted"
+	AuditRoleRevoked            AuditAction = "role.revoked"
+	AuditRolePermissionsAdded   AuditAction = "role.permissions_added"
+	AuditRolePermissionsRemoved AuditAction = "role.permissions_removed"
+	AuditReauthenticated        AuditAction = "reauthenticated"
+	AuditTwoFactorEnabled       AuditAction = "two_factor.enabled"
+	AuditTwoFactorDisabled      AuditAction = "two_factor.disabled"
+	AuditLockoutCleared         AuditAction = "lockout.cleared"
+	AuditAPIKeyCreated          AuditAction = "api_key.created"
+	AuditAPIKeyRevoked          AuditAction = "api_key.revoked"
+	AuditOrgDeleted             AuditAction = "organization.deleted"
+	AuditOrgMemberRemoved       AuditAction = "organization.member_removed"
+)
+
+// AuditEvent records a security-relevant action. The actor is the user who performed it,
+// if anyone was signed in, and the target the user it was performed on. Events are
+// append-only; the database rejects updates and deletes.
+type AuditEvent struct {
+	ID        int64              `json:"id"`
+	ActorID   pgtype.Int8        `json:"actor_id"`
+	TargetID  pgtype.Int8        `json:"target_id"`
+	Action    AuditAction        `json:"action"`
+	IP        string             `json:"ip"`
+	UserAgent string             `json:"user_agent"`
+	Metadata  map[string]any     `json:"metadata"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+func (event *AuditEvent) fromSQLCAuditEvent(e sqlc.AuditEvent) error {
+	*event = AuditEvent{
+		ID:        e.ID,
+		ActorID:   e.ActorID,
+		TargetID:  e.TargetID,
+		Action:    AuditAction(e.Action),
+		IP:        e.Ip,
+		UserAgent: e.UserAgent,
+		CreatedAt: e.CreatedAt,
+	}
+	return json.Unmarshal(e.Metadata, &event.Metadata)
+}
+
+// AuditUserID returns the user ID as an actor or target, treating 0 as nobody.
+func AuditUserID(id int64) pgtype.Int8 {
+	return pgtype.Int8{Int64: id, Valid: id != 0}
+}
+
+// AuditFilter narrows down the audit events listed. Zero fields don't filter.
+type AuditFilter struct {
+	ActorID  int64
+	TargetID int64
+	Action   AuditAction
+	Since    pgtype.Timestamptz
+	Until    pgtype.Timestamptz
+}
+
+type AuditModel struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+}
+
+func (m AuditModel) Insert(ctx context.Context, event *AuditEvent) error {
+	metadata := event.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+
+	rawMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	return m.queries.InsertAuditEvent(ctx, sqlc.InsertAuditEventParams{
+		ActorID:   event.ActorID,
+		TargetID:  event.TargetID,
+		Action:    string(event.Action),
+		Ip:        event.IP,
+		UserAgent: event.UserAgent,
+		Metadata:  rawMetadata,
+	})
+}
+
+// GetAll returns the events matching the filter, newest first.
+func (m AuditModel) GetAll(ctx context.Context, filter AuditFilter, filters Filters) ([]*AuditEvent, Metadata, error) {
+	rows, err := m.queries.GetAllAuditEvents(ctx, sqlc.GetAllAuditEventsParams{
+		ActorID:   AuditUserID(filter.ActorID),
+		TargetID:  AuditUserID(filter.TargetID),
+		Action:    pgtype.Text{String: string(filter.Action), Valid: filter.Action != ""},
+		Since:     filter.Since,
+		Until:     filter.Until,
+		RowLimit:  int32(filters.limit()),
+		RowOffset: int32(filters.offset()),
+	})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	totalRecords := 0
+	events := make([]*AuditEvent, len(rows))
+	for i, row := range rows {
+		totalRecords = int(row.Total)
+		events[i] = &AuditEvent{}
+		err = events[i].fromSQLCAuditEvent(sqlc.AuditEvent{
+			ID:        row.ID,
+			ActorID:   row.ActorID,
+			TargetID:  row.TargetID,
+			Action:    row.Action,
+			Ip:        row.Ip,
+			UserAgent: row.UserAgent,
+			Metadata:  row.Metadata,
+			CreatedAt: row.CreatedAt,
+		})
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+
+	return events, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// GetAllForUser returns the events the user performed or was the target of, newest first.
+func (m AuditModel) GetAllForUser(ctx context.Context, userID int64) ([]*AuditEvent, error) {
+	rows, err := m.queries.GetAllAuditEventsForUser(ctx, AuditUserID(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*AuditEvent, len(rows))
+	for i, row := range rows {
+		events[i] = &AuditEvent{}
+		err = events[i].fromSQLCAuditEvent(row)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// IsNewDevice reports whether the user has logged in before, but never with the user agent.
+// A user's first login is not considered to be on a new device.
+func (m AuditModel) IsNewDevice(ctx context.Context, userID int64, userAgent string) (bool, error) {
+	history, err := m.queries.GetLoginHistory(ctx, sqlc.GetLoginHistoryParams{
+		UserID:    AuditUserID(userID),
+		UserAgent: userAgent,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return history.HasLogins && !history.KnownUserAgent, nil
+}
@@ -0,0 +1,145 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"adcentra.ai/internal/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrDuplicateIdentity = errors.New("duplicate identity")
+)
+
+// UserIdentity links a user to an account at an external OpenID Connect provider, identified
+// by the provider's stable subject identifier.
+type UserIdentity struct {
+	Base
+
+	ID       int64  `json:"id"`
+	UserID   int64  `json:"-"`
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+	Email    string `json:"email"`
+}
+
+type IdentityModel struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+}
+
+func (m IdentityModel) GetUser(ctx context.Context, provider, subject string) (*User, error) {
+	u, err := m.queries.GetUserForIdentity(ctx, sqlc.GetUserForIdentityParams{
+		Provider: provider,
+		Subject:  subject,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	var user User
+	user.fromSQLCUser(u)
+	return &user, nil
+}
+
+func (m IdentityModel) GetAllForUser(ctx context.Context, userID int64) ([]*UserIdentity, error) {
+	rows, err := m.queries.GetAllIdentitiesForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	identities := make([]*UserIdentity, len(rows))
+	for i, row := range rows {
+		identities[i] = &UserIdentity{
+			Base: Base{
+				CreatedAt: row.CreatedAt,
+				UpdatedAt: row.UpdatedAt,
+			},
+			ID:       row.ID,
+			UserID:   row.UserID,
+			Provider: row.Provider,
+			Subject:  row.Subject,
+			Email:    row.Email,
+		}
+	}
+	return identities, nil
+}
+
+func (m IdentityModel) Insert(ctx context.Context, identity *UserIdentity) error {
+	return m.insert(ctx, m.queries, identity)
+}
+
+// InsertWithUser creates a new user together with its first identity.
+func (m IdentityModel) InsertWithUser(ctx context.Context, user *User, identity *UserIdentity) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	insertedUser, err := qtx.InsertUser(ctx, sqlc.InsertUserParams{
+		FullName:        user.FullName,
+		Email:           user.Email,
+		ProfileImageUrl: user.ProfileImageURL,
+		PasswordHash:    user.Password.hash,
+		Activated:       user.Activated,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			if pgErr.Code == strconv.Itoa(23505) && strings.Contains(pgErr.ConstraintName, "users_email_key") {
+				return ErrDuplicateEmail
+			}
+		}
+		return err
+	}
+
+	user.ID = insertedUser.ID
+	user.LastLoginAt = insertedUser.LastLoginAt
+	user.CreatedAt = insertedUser.CreatedAt
+	user.UpdatedAt = insertedUser.UpdatedAt
+	user.Version = insertedUser.Version
+
+	identity.UserID = user.ID
+	err = m.insert(ctx, qtx, identity)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (m IdentityModel) insert(ctx context.Context, queries *sqlc.Queries, identity *UserIdentity) error {
+	i, err := queries.InsertUserIdentity(ctx, sqlc.InsertUserIdentityParams{
+		UserID:   identity.UserID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			if pgErr.Code == strconv.Itoa(23505) && strings.Contains(pgErr.ConstraintName, "user_identities_provider_subject_key") {
+				return ErrDuplicateIdentity
+			}
+		}
+		return err
+	}
+
+	identity.ID = i.ID
+	identity.CreatedAt = i.CreatedAt
+	identity.UpdatedAt = i.UpdatedAt
+	return nil
+}
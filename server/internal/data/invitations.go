@@ -0,0 +1,217 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	"adcentra.ai/internal/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Invitation invites an email address to join an organization with a role. It hangs off a
+// token of the invitation scope issued to the inviter, so it expires and is cleaned up like
+// any other token.
+type Invitation struct {
+	ID               int64              `json:"id"`
+	OrganizationID   int64              `json:"organization_id"`
+	OrganizationName string             `json:"organization_name,omitempty"`
+	Email            string             `json:"email"`
+	Role             Role               `json:"role"`
+	InvitedBy        int64              `json:"invited_by"`
+	Expiry           pgtype.Timestamptz `json:"expiry"`
+	CreatedAt        pgtype.Timestamptz `json:"created_at"`
+	TokenHash        []byte             `json:"-"`
+	Plaintext        string             `json:"-"`
+}
+
+type InvitationModel struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+}
+
+func (m InvitationModel) New(ctx context.Context, orgID, inviterID int64, email string, role Role, ttl time.Duration) (*Invitation, error) {
+	token := generateToken(inviterID, ttl, ScopeInvitation)
+	token.Email = pgtype.Text{String: email, Valid: true}
+
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	_, err = qtx.InsertToken(ctx, sqlc.InsertTokenParams{
+		Hash:   token.Hash,
+		UserID: token.UserID,
+		Expiry: token.Expiry,
+		Scope:  token.Scope,
+		Email:  token.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := qtx.InsertInvitation(ctx, sqlc.InsertInvitationParams{
+		TokenHash:      token.Hash,
+		OrganizationID: orgID,
+		RoleCode:       string(role),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Invitation{
+		ID:             i.ID,
+		OrganizationID: i.OrganizationID,
+		Email:          email,
+		Role:           role,
+		InvitedBy:      inviterID,
+		Expiry:         token.Expiry,
+		CreatedAt:      i.CreatedAt,
+		TokenHash:      token.Hash,
+		Plaintext:      token.Plaintext,
+	}, nil
+}
+
+// GetForToken returns the unexpired invitation for the plaintext token.
+func (m InvitationModel) GetForToken(ctx context.Context, tokenPlaintext string) (*Invitation, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	row, err := m.queries.GetInvitationForToken(ctx, sqlc.GetInvitationForTokenParams{
+		Hash:              tokenHash[:],
+		Scope:             ScopeInvitation,
+		ExpiryGreaterThan: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &Invitation{
+		ID:               row.Invitation.ID,
+		OrganizationID:   row.Invitation.OrganizationID,
+		OrganizationName: row.OrganizationName,
+		Email:            row.Token.Email.String,
+		Role:             Role(row.Role),
+		InvitedBy:        row.Token.UserID,
+		Expiry:           row.Token.Expiry,
+		CreatedAt:        row.Invitation.CreatedAt,
+		TokenHash:        row.Token.Hash,
+	}, nil
+}
+
+// GetAllForOrganization returns the pending invitations of the organization, newest first.
+func (m InvitationModel) GetAllForOrganization(ctx context.Context, orgID int64) ([]*Invitation, error) {
+	rows, err := m.queries.GetAllInvitationsForOrganization(ctx, sqlc.GetAllInvitationsForOrganizationParams{
+		OrganizationID:    orgID,
+		ExpiryGreaterThan: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invitations := make([]*Invitation, len(rows))
+	for i, row := range rows {
+		invitations[i] = &Invitation{
+			ID:             row.Invitation.ID,
+			OrganizationID: row.Invitation.OrganizationID,
+			Email:          row.Token.Email.String,
+			Role:           Role(row.Role),
+			InvitedBy:      row.Token.UserID,
+			Expiry:         row.Token.Expiry,
+			CreatedAt:      row.Invitation.CreatedAt,
+			TokenHash:      row.Token.Hash,
+		}
+	}
+	return invitations, nil
+}
+
+// Delete revokes the invitation by deleting its token.
+func (m InvitationModel) Delete(ctx context.Context, id, orgID int64) error {
+	result, err := m.queries.DeleteInvitation(ctx, sqlc.DeleteInvitationParams{
+		ID:             id,
+		OrganizationID: orgID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Accept makes the user a member of the organization with the invited role and uses up the
+// invitation.
+func (m InvitationModel) Accept(ctx context.Context, invitation *Invitation, userID int64) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	err = addOrganizationMember(ctx, qtx, invitation.OrganizationID, userID, invitation.Role)
+	if err != nil {
+		return err
+	}
+
+	// The invitation is deleted along with its token.
+	err = qtx.DeleteByHash(ctx, invitation.TokenHash)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// AcceptForNewUser registers the user and makes them a member of the organization in one
+// go, so that a failed registration doesn't use up the invitation.
+func (m InvitationModel) AcceptForNewUser(ctx context.Context, invitation *Invitation, user *User) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	err = insertUser(ctx, qtx, user)
+	if err != nil {
+		return err
+	}
+
+	err = addOrganizationMember(ctx, qtx, invitation.OrganizationID, user.ID, invitation.Role)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.DeleteByHash(ctx, invitation.TokenHash)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
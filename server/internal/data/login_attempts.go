@@ -0,0 +1,143 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"adcentra.ai/internal/cache"
+	"adcentra.ai/internal/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const maxLockoutDuration = 24 * time.Hour
+
+// LockoutPolicy controls how many failed logins are tolerated for an email
+// before it is locked, and for how long.
+type LockoutPolicy struct {
+	MaxAttempts     int
+	LockoutDuration time.Duration
+	// Failures older than Window no longer count towards a lockout.
+	Window time.Duration
+}
+
+// LockDuration returns how long an email should be locked after failedCount
+// consecutive failures, or 0 if no new lock applies. A lock is applied on
+// every MaxAttempts-th failure and doubles each time, up to 24 hours.
+func (p LockoutPolicy) LockDuration(failedCount int) time.Duration {
+	if p.MaxAttempts <= 0 || failedCount < p.MaxAttempts || failedCount%p.MaxAttempts != 0 {
+		return 0
+	}
+
+	duration := p.LockoutDuration
+	for i := failedCount / p.MaxAttempts; i > 1 && duration < maxLockoutDuration; i-- {
+		duration *= 2
+	}
+	return min(duration, maxLockoutDuration)
+}
+
+type LoginAttempts struct {
+	Email        string     `json:"email"`
+	FailedCount  int        `json:"failed_count"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until"`
+}
+
+func (a *LoginAttempts) Locked() bool {
+	return a.LockedUntil != nil && time.Now().Before(*a.LockedUntil)
+}
+
+// RetryAfter returns the time remaining until the lock expires.
+func (a *LoginAttempts) RetryAfter() time.Duration {
+	if !a.Locked() {
+		return 0
+	}
+	return time.Until(*a.LockedUntil)
+}
+
+func fromSQLCLoginAttempt(a sqlc.LoginAttempt) *LoginAttempts {
+	attempts := &LoginAttempts{
+		Email:        a.Email,
+		FailedCount:  int(a.FailedCount),
+		LastFailedAt: a.LastFailedAt.Time,
+	}
+	if a.LockedUntil.Valid {
+		attempts.LockedUntil = &a.LockedUntil.Time
+	}
+	return attempts
+}
+
+type LoginAttemptModel struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+	cache   cache.Cache
+}
+
+const LoginAttemptsCacheTTL = 15 * time.Minute
+
+// Get returns the failed login state for an email. An email without any
+// recorded failures gets a zero value rather than ErrRecordNotFound.
+func (m LoginAttemptModel) Get(ctx context.Context, email string) (*LoginAttempts, error) {
+	key := cache.GenerateLoginAttemptsKey(email)
+	var attempts LoginAttempts
+	if cache.TryGet(ctx, m.cache, key, &attempts) {
+		return &attempts, nil
+	}
+
+	row, err := m.queries.GetLoginAttempts(ctx, email)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return &LoginAttempts{Email: email}, nil
+		default:
+			return nil, err
+		}
+	}
+
+	result := fromSQLCLoginAttempt(row)
+	cache.TrySet(ctx, m.cache, key, result, LoginAttemptsCacheTTL)
+	return result, nil
+}
+
+// RecordFailure counts a failed login for the email and locks it when the
+// policy says so. The returned bool reports whether a new lock was applied.
+func (m LoginAttemptModel) RecordFailure(ctx context.Context, email string, policy LockoutPolicy) (*LoginAttempts, bool, error) {
+	row, err := m.queries.RecordFailedLogin(ctx, sqlc.RecordFailedLoginParams{
+		Email:       email,
+		ResetBefore: pgtype.Timestamptz{Time: time.Now().Add(-policy.Window), Valid: true},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	attempts := fromSQLCLoginAttempt(row)
+
+	locked := false
+	if duration := policy.LockDuration(attempts.FailedCount); duration > 0 {
+		lockedUntil := time.Now().Add(duration)
+		err = m.queries.LockLogin(ctx, sqlc.LockLoginParams{
+			Email:       email,
+			LockedUntil: pgtype.Timestamptz{Time: lockedUntil, Valid: true},
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		attempts.LockedUntil = &lockedUntil
+		locked = true
+	}
+
+	cache.TrySet(ctx, m.cache, cache.GenerateLoginAttemptsKey(email), attempts, LoginAttemptsCacheTTL)
+	return attempts, locked, nil
+}
+
+// Reset clears failed logins and any lock for the email.
+func (m LoginAttemptModel) Reset(ctx context.Context, email string) error {
+	cache.TryInvalidate(ctx, m.cache, cache.GenerateLoginAttemptsKey(email))
+	return m.queries.DeleteLoginAttempts(ctx, email)
+}
+
+func (m LoginAttemptModel) DeleteStale(ctx context.Context, olderThan time.Duration) error {
+	return m.queries.DeleteStaleLoginAttempts(ctx, pgtype.Timestamptz{Time: time.Now().Add(-olderThan), Valid: true})
+}
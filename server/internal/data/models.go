@@ -5,6 +5,7 @@ import (
 
 	"adcentra.ai/internal/cache"
 	"adcentra.ai/internal/db/sqlc"
+	"adcentra.ai/internal/hasher"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -15,20 +16,36 @@ var (
 )
 
 type Models struct {
-	Users       UserModel
-	Tokens      TokenModel
-	Sessions    SessionModel
-	Permissions PermissionModel
-	Roles       RoleModel
+	Users         UserModel
+	Tokens        TokenModel
+	Sessions      SessionModel
+	Permissions   PermissionModel
+	Roles         RoleModel
+	TwoFactor     TwoFactorModel
+	Identities    IdentityModel
+	Logins        LoginAttemptModel
+	APIKeys       APIKeyModel
+	Organizations OrganizationModel
+	Invitations   InvitationModel
+	Audit         AuditModel
 }
 
-func NewModels(pool *pgxpool.Pool, cacheInstance cache.Cache) Models {
+// NewModels returns the models. User passwords and recovery codes are hashed with
+// passwordHasher.
+func NewModels(pool *pgxpool.Pool, cacheInstance cache.Cache, passwordHasher *hasher.Hasher) Models {
 	queries := sqlc.New(pool)
 	return Models{
-		Users:       UserModel{pool: pool, queries: queries},
-		Tokens:      TokenModel{pool: pool, queries: queries},
-		Sessions:    SessionModel{pool: pool, queries: queries},
-		Permissions: PermissionModel{pool: pool, queries: queries, cache: cacheInstance},
-		Roles:       RoleModel{pool: pool, queries: queries, cache: cacheInstance},
+		Users:         UserModel{pool: pool, queries: queries, cache: cacheInstance, hasher: passwordHasher},
+		Tokens:        TokenModel{pool: pool, queries: queries},
+		Sessions:      SessionModel{pool: pool, queries: queries},
+		Permissions:   PermissionModel{pool: pool, queries: queries, cache: cacheInstance},
+		Roles:         RoleModel{pool: pool, queries: queries, cache: cacheInstance},
+		TwoFactor:     TwoFactorModel{pool: pool, queries: queries, hasher: passwordHasher},
+		Identities:    IdentityModel{pool: pool, queries: queries},
+		Logins:        LoginAttemptModel{pool: pool, queries: queries, cache: cacheInstance},
+		APIKeys:       APIKeyModel{pool: pool, queries: queries},
+		Organizations: OrganizationModel{pool: pool, queries: queries},
+		Invitations:   InvitationModel{pool: pool, queries: queries},
+		Audit:         AuditModel{pool: pool, queries: queries},
 	}
 }
@@ -0,0 +1,355 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"adcentra.ai/internal/db/sqlc"
+	i18n "adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/validator"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	ErrDuplicateOrganizationSlug = errors.New("duplicate organization slug")
+	ErrAlreadyMember             = errors.New("already a member")
+	ErrLastOwner                 = errors.New("last owner")
+
+	slugRX = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+)
+
+// Organization is a workspace that users join as members. Within an organization a member
+// acts with the permissions of their organization role instead of their global roles.
+type Organization struct {
+	ID        int64              `json:"id"`
+	Name      string             `json:"name"`
+	Slug      string             `json:"slug"`
+	Version   int32              `json:"-"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"-"`
+	// Role is the role of the user the organization was loaded for, if any.
+	Role Role `json:"role,omitempty"`
+}
+
+func (org *Organization) fromSQLCOrganization(o sqlc.Organization) {
+	*org = Organization{
+		ID:        o.ID,
+		Name:      o.Name,
+		Slug:      o.Slug,
+		Version:   o.Version,
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+	}
+}
+
+// Membership is the role of a user in an organization.
+type Membership struct {
+	OrganizationID int64              `json:"-"`
+	UserID         int64              `json:"-"`
+	Role           Role               `json:"role"`
+	Permissions    Permissions        `json:"-"`
+	JoinedAt       pgtype.Timestamptz `json:"joined_at"`
+	User           *User              `json:"user,omitempty"`
+}
+
+func ValidateOrganization(v *validator.Validator, localizer *goi18n.Localizer, org *Organization) {
+	v.Check(validator.NotBlank(org.Name), "name", i18n.LocalizeMessage(localizer, "OrganizationNameMustBeProvided", nil))
+	v.Check(validator.MaxChars(org.Name, 64), "name", i18n.LocalizeMessage(localizer, "OrganizationNameMustNotBeMoreThan64CharactersLong", nil))
+
+	v.Check(validator.NotBlank(org.Slug), "slug", i18n.LocalizeMessage(localizer, "OrganizationSlugMustBeProvided", nil))
+	v.Check(validator.MaxChars(org.Slug, 64), "slug", i18n.LocalizeMessage(localizer, "OrganizationSlugMustNotBeMoreThan64CharactersLong", nil))
+	v.Check(validator.Matches(org.Slug, slugRX), "slug", i18n.LocalizeMessage(localizer, "OrganizationSlugMustBeValid", nil))
+}
+
+type OrganizationModel struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+}
+
+// New creates the organization with the given user as its owner.
+func (m OrganizationModel) New(ctx context.Context, org *Organization, ownerID int64) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	o, err := qtx.InsertOrganization(ctx, sqlc.InsertOrganizationParams{
+		Name: org.Name,
+		Slug: org.Slug,
+	})
+	if err != nil {
+		return duplicateOrganizationSlugError(err)
+	}
+
+	err = addOrganizationMember(ctx, qtx, o.ID, ownerID, RoleOrgOwner)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	org.fromSQLCOrganization(o)
+	org.Role = RoleOrgOwner
+	return nil
+}
+
+func (m OrganizationModel) Get(ctx context.Context, id int64) (*Organization, error) {
+	o, err := m.queries.GetOrganizationByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	var org Organization
+	org.fromSQLCOrganization(o)
+	return &org, nil
+}
+
+// GetAllForUser returns the organizations the user is a member of, with their role in each.
+func (m OrganizationModel) GetAllForUser(ctx context.Context, userID int64) ([]*Organization, error) {
+	rows, err := m.queries.GetAllOrganizationsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := make([]*Organization, len(rows))
+	for i, row := range rows {
+		orgs[i] = &Organization{}
+		orgs[i].fromSQLCOrganization(row.Organization)
+		orgs[i].Role = Role(row.Role)
+	}
+	return orgs, nil
+}
+
+func (m OrganizationModel) Update(ctx context.Context, org *Organization) error {
+	version, err := m.queries.UpdateOrganization(ctx, sqlc.UpdateOrganizationParams{
+		Name:    org.Name,
+		Slug:    org.Slug,
+		ID:      org.ID,
+		Version: org.Version,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return duplicateOrganizationSlugError(err)
+		}
+	}
+
+	org.Version = version
+	return nil
+}
+
+// Delete removes the organization along with its memberships and invitations.
+func (m OrganizationModel) Delete(ctx context.Context, id int64) error {
+	result, err := m.queries.DeleteOrganization(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetMembership returns the role of the user in the organization along with the
+// permissions it grants.
+func (m OrganizationModel) GetMembership(ctx context.Context, orgID, userID int64) (*Membership, error) {
+	row, err := m.queries.GetOrganizationMembership(ctx, sqlc.GetOrganizationMembershipParams{
+		OrganizationID: orgID,
+		UserID:         userID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	membership := &Membership{
+		OrganizationID: row.OrganizationID,
+		UserID:         row.UserID,
+		Role:           Role(row.Role),
+		Permissions:    Permissions{},
+		JoinedAt:       row.CreatedAt,
+	}
+	membership.Permissions.FromStrings(row.Permissions)
+	return membership, nil
+}
+
+func (m OrganizationModel) GetAllMembers(ctx context.Context, orgID int64) ([]*Membership, error) {
+	rows, err := m.queries.GetAllOrganizationMembers(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]*Membership, len(rows))
+	for i, row := range rows {
+		var user User
+		user.fromSQLCUser(row.User)
+		members[i] = &Membership{
+			OrganizationID: orgID,
+			UserID:         user.ID,
+			Role:           Role(row.Role),
+			JoinedAt:       row.JoinedAt,
+			User:           &user,
+		}
+	}
+	return members, nil
+}
+
+func (m OrganizationModel) AddMember(ctx context.Context, orgID, userID int64, role Role) error {
+	return addOrganizationMember(ctx, m.queries, orgID, userID, role)
+}
+
+func addOrganizationMember(ctx context.Context, q *sqlc.Queries, orgID, userID int64, role Role) error {
+	result, err := q.InsertOrganizationMember(ctx, sqlc.InsertOrganizationMemberParams{
+		OrganizationID: orgID,
+		UserID:         userID,
+		RoleCode:       string(role),
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			if pgErr.Code == strconv.Itoa(23505) && strings.Contains(pgErr.ConstraintName, "organization_members_pkey") {
+				return ErrAlreadyMember
+			}
+		}
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// UpdateMemberRole changes the role of a member. The last owner of an organization cannot
+// be demoted, so that there is always someone who can manage it.
+func (m OrganizationModel) UpdateMemberRole(ctx context.Context, orgID, userID int64, role Role) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	if role != RoleOrgOwner {
+		err = m.checkNotLastOwner(ctx, qtx, orgID, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := qtx.UpdateOrganizationMemberRole(ctx, sqlc.UpdateOrganizationMemberRoleParams{
+		OrganizationID: orgID,
+		UserID:         userID,
+		RoleCode:       string(role),
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RemoveMember removes the user from the organization, unless they are its last owner.
+func (m OrganizationModel) RemoveMember(ctx context.Context, orgID, userID int64) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	err = m.checkNotLastOwner(ctx, qtx, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	result, err := qtx.DeleteOrganizationMember(ctx, sqlc.DeleteOrganizationMemberParams{
+		OrganizationID: orgID,
+		UserID:         userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (m OrganizationModel) checkNotLastOwner(ctx context.Context, q *sqlc.Queries, orgID, userID int64) error {
+	row, err := q.GetOrganizationMembership(ctx, sqlc.GetOrganizationMembershipParams{
+		OrganizationID: orgID,
+		UserID:         userID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if Role(row.Role) != RoleOrgOwner {
+		return nil
+	}
+
+	owners, err := q.CountOrganizationMembersWithRole(ctx, sqlc.CountOrganizationMembersWithRoleParams{
+		OrganizationID: orgID,
+		RoleCode:       string(RoleOrgOwner),
+	})
+	if err != nil {
+		return err
+	}
+
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
+
+func duplicateOrganizationSlugError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if pgErr.Code == strconv.Itoa(23505) && strings.Contains(pgErr.ConstraintName, "organizations_slug_key") {
+			return ErrDuplicateOrganizationSlug
+		}
+	}
+	return err
+}
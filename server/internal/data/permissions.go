@@ -2,18 +2,26 @@ package data
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"slices"
 
 	"adcentra.ai/internal/cache"
 	"adcentra.ai/internal/db/sqlc"
+	i18n "adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/validator"
 	"github.com/jackc/pgx/v5/pgxpool"
+	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
 type PermCode string
 
 const (
+	// PermAll grants every permission. Each resource also has a wildcard, such as users:*,
+	// granting every permission on it.
+	PermAll PermCode = "*"
+
 	PermDebugVarsView PermCode = "debugvars:view"
 	PermAdminsView    PermCode = "admins:view"
 	PermAdminsManage  PermCode = "admins:manage"
@@ -21,8 +29,80 @@ const (
 	PermUsersManage   PermCode = "users:manage"
 	PermRolesView     PermCode = "roles:view"
 	PermRolesManage   PermCode = "roles:manage"
+	PermAuditView     PermCode = "audit:view"
+
+	// Organization permissions are evaluated against the role of the member in the
+	// active organization.
+	PermOrgView       PermCode = "org:view"
+	PermOrgManage     PermCode = "org:manage"
+	PermMembersView   PermCode = "members:view"
+	PermMembersManage PermCode = "members:manage"
 )
 
+// permissionCatalog declares every concrete permission. It is the source of truth for the
+// permissions table, which updatedefaults keeps in sync with PermissionCatalog.
+var permissionCatalog = Permissions{
+	PermDebugVarsView,
+	PermAdminsView,
+	PermAdminsManage,
+	PermUsersView,
+	PermUsersManage,
+	PermRolesView,
+	PermRolesManage,
+	PermAuditView,
+	PermOrgView,
+	PermOrgManage,
+	PermMembersView,
+	PermMembersManage,
+}
+
+// permissionImplications lists the permissions each permission implies on its own. The graph
+// must be acyclic.
+var permissionImplications = map[PermCode]Permissions{
+	PermAdminsManage:  {PermAdminsView},
+	PermUsersManage:   {PermUsersView},
+	PermRolesManage:   {PermRolesView},
+	PermOrgManage:     {PermOrgView},
+	PermMembersManage: {PermMembersView},
+}
+
+// PermissionCatalog returns every grantable permission: the concrete ones, the wildcard of
+// each resource and PermAll.
+func PermissionCatalog() Permissions {
+	catalog := Permissions{PermAll}
+	for _, code := range permissionCatalog {
+		if wildcard := code.resourceWildcard(); !catalog.contains(wildcard) {
+			catalog = append(catalog, wildcard)
+		}
+	}
+	return append(catalog, permissionCatalog...)
+}
+
+// resourceWildcard returns the wildcard of the resource the permission belongs to, e.g.
+// users:* for users:view.
+func (code PermCode) resourceWildcard() PermCode {
+	resource, _, _ := strings.Cut(string(code), ":")
+	return PermCode(resource + ":*")
+}
+
+// Grants reports whether holding the permission grants code, directly, through a wildcard
+// or through the implication graph.
+func (code PermCode) Grants(other PermCode) bool {
+	switch {
+	case code == other, code == PermAll:
+		return true
+	case strings.HasSuffix(string(code), ":*") && code == other.resourceWildcard():
+		return true
+	}
+
+	for _, implied := range permissionImplications[code] {
+		if implied.Grants(other) {
+			return true
+		}
+	}
+	return false
+}
+
 type Permissions []PermCode
 
 func (p Permissions) ToStrings() []string {
@@ -39,13 +119,19 @@ func (p *Permissions) FromStrings(strings []string) {
 	}
 }
 
+// Has reports whether any of the permissions grants code.
 func (p Permissions) Has(code PermCode) bool {
-	return slices.Contains(p, code)
+	for _, held := range p {
+		if held.Grants(code) {
+			return true
+		}
+	}
+	return false
 }
 
 func (p Permissions) HasAll(codes ...PermCode) bool {
 	for _, code := range codes {
-		if !slices.Contains(p, code) {
+		if !p.Has(code) {
 			return false
 		}
 	}
@@ -54,13 +140,26 @@ func (p Permissions) HasAll(codes ...PermCode) bool {
 
 func (p Permissions) HasAny(codes ...PermCode) bool {
 	for _, code := range codes {
-		if slices.Contains(p, code) {
+		if p.Has(code) {
 			return true
 		}
 	}
 	return false
 }
 
+// contains reports whether code is literally one of the permissions, without wildcards or
+// implications.
+func (p Permissions) contains(code PermCode) bool {
+	return slices.Contains(p, code)
+}
+
+// CanGrantPermissions reports whether someone with the given roles and permissions may add
+// the permissions to a role. Like roles, permissions can only be handed out by those who
+// hold them.
+func CanGrantPermissions(roles Roles, permissions Permissions, granted Permissions) bool {
+	return roles.Has(RoleSuperAdmin) || permissions.HasAll(granted...)
+}
+
 type PermissionModel struct {
 	pool    *pgxpool.Pool
 	queries *sqlc.Queries
@@ -89,6 +188,21 @@ func (m PermissionModel) InvalidateAllForUserCache(ctx context.Context, userID i
 	cache.TryInvalidate(ctx, m.cache, key)
 }
 
+// InvalidateAllCache drops the cached permissions of every user. Changing the permissions
+// of a role affects all of its users, so this is cheaper than looking them up.
+func (m PermissionModel) InvalidateAllCache(ctx context.Context) {
+	cache.TryInvalidatePattern(ctx, m.cache, cache.KeyPermissionsPrefix+"*")
+}
+
+// GetAll returns every known permission code.
+func (m PermissionModel) GetAll(ctx context.Context) (Permissions, error) {
+	stringPerms, err := m.queries.GetAllPermissions(ctx)
+
+	permissions := Permissions{}
+	permissions.FromStrings(stringPerms)
+	return permissions, err
+}
+
 // Fetches all permissions of roles assigned to user
 func (m PermissionModel) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
 	stringPerms, err := m.queries.GetAllPermissionsForUser(ctx, userID)
@@ -106,11 +220,25 @@ func (m PermissionModel) GetAllForRole(ctx context.Context, roleID int64) (Permi
 	return permissions, err
 }
 
+func (m PermissionModel) GetAllForRoleCode(ctx context.Context, roleCode Role) (Permissions, error) {
+	stringPerms, err := m.queries.GetAllPermissionsForRoleCode(ctx, string(roleCode))
+
+	permissions := Permissions{}
+	permissions.FromStrings(stringPerms)
+	return permissions, err
+}
+
 func (m PermissionModel) AddForRole(ctx context.Context, roleCode Role, permissions Permissions) error {
-	return m.queries.AddPermissionsForRole(ctx, sqlc.AddPermissionsForRoleParams{
+	err := m.queries.AddPermissionsForRole(ctx, sqlc.AddPermissionsForRoleParams{
 		RoleCode:  string(roleCode),
 		PermCodes: permissions.ToStrings(),
 	})
+	if err != nil {
+		return err
+	}
+
+	m.InvalidateAllCache(ctx)
+	return nil
 }
 
 func (m PermissionModel) RemoveForRole(ctx context.Context, roleCode Role, permissions Permissions) error {
@@ -127,9 +255,55 @@ func (m PermissionModel) RemoveForRole(ctx context.Context, roleCode Role, permi
 		return ErrRecordNotFound
 	}
 
+	m.InvalidateAllCache(ctx)
 	return nil
 }
 
 func (m PermissionModel) Create(ctx context.Context, permCodes Permissions) error {
 	return m.queries.Create(ctx, permCodes.ToStrings())
 }
+
+// Sync makes the permissions table match the catalog. Permissions missing from the catalog
+// are deleted along with their grants to roles.
+func (m PermissionModel) Sync(ctx context.Context, catalog Permissions) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	err = qtx.Create(ctx, catalog.ToStrings())
+	if err != nil {
+		return err
+	}
+
+	result, err := qtx.DeleteAllPermissionsExcept(ctx, catalog.ToStrings())
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() > 0 {
+		m.InvalidateAllCache(ctx)
+	}
+	return nil
+}
+
+// ValidatePermissionCodes checks that at least one permission was given and that all of them
+// exist in the catalog.
+func ValidatePermissionCodes(v *validator.Validator, localizer *goi18n.Localizer, permissions, catalog Permissions) {
+	v.Check(len(permissions) > 0, "permissions", i18n.LocalizeMessage(localizer, "PermissionsMustBeProvided", nil))
+
+	for _, code := range permissions {
+		if !catalog.contains(code) {
+			v.AddError("permissions", i18n.LocalizeMessage(localizer, "PermissionsMustExist", nil))
+			break
+		}
+	}
+}
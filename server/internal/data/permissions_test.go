@@ -0,0 +1,88 @@
+package data_test
+
+import (
+	"testing"
+
+	"adcentra.ai/internal/data"
+)
+
+func TestCanGrantPermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		roles       data.Roles
+		permissions data.Permissions
+		granted     data.Permissions
+		want        bool
+	}{
+		{
+			name:    "superadmin grants anything",
+			roles:   data.Roles{data.RoleSuperAdmin},
+			granted: data.Permissions{data.PermAll},
+			want:    true,
+		},
+		{
+			name:        "held permissions",
+			roles:       data.Roles{data.RoleAdmin},
+			permissions: data.Permissions{data.PermUsersManage, data.PermDebugVarsView},
+			granted:     data.Permissions{data.PermUsersManage, data.PermDebugVarsView},
+			want:        true,
+		},
+		{
+			name:        "implied permission",
+			roles:       data.Roles{data.RoleAdmin},
+			permissions: data.Permissions{data.PermRolesManage},
+			granted:     data.Permissions{data.PermRolesView},
+			want:        true,
+		},
+		{
+			name:        "held through a wildcard",
+			roles:       data.Roles{data.RoleAdmin},
+			permissions: data.Permissions{"users:*"},
+			granted:     data.Permissions{data.PermUsersView, data.PermUsersManage},
+			want:        true,
+		},
+		{
+			name:        "held through the catch-all",
+			roles:       data.Roles{data.RoleAdmin},
+			permissions: data.Permissions{data.PermAll},
+			granted:     data.Permissions{data.PermAdminsManage},
+			want:        true,
+		},
+		{
+			name:        "one permission not held",
+			roles:       data.Roles{data.RoleAdmin},
+			permissions: data.Permissions{data.PermUsersManage},
+			granted:     data.Permissions{data.PermUsersManage, data.PermDebugVarsView},
+			want:        false,
+		},
+		{
+			name:        "implication doesn't go upwards",
+			roles:       data.Roles{data.RoleAdmin},
+			permissions: data.Permissions{data.PermRolesView},
+			granted:     data.Permissions{data.PermRolesManage},
+			want:        false,
+		},
+		{
+			name:        "wildcard not held",
+			roles:       data.Roles{data.RoleAdmin},
+			permissions: data.Permissions{data.PermUsersView, data.PermUsersManage},
+			granted:     data.Permissions{"users:*"},
+			want:        false,
+		},
+		{
+			name:    "nothing granted",
+			roles:   data.Roles{data.RoleUser},
+			granted: data.Permissions{},
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := data.CanGrantPermissions(tt.roles, tt.permissions, tt.granted)
+			if got != tt.want {
+				t.Errorf("CanGrantPermissions() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
@@ -11,18 +12,28 @@ import (
 
 	"adcentra.ai/internal/cache"
 	"adcentra.ai/internal/db/sqlc"
+	i18n "adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/validator"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
+	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
 type Role string
 
+// Built-in roles. Custom roles can be created at runtime through the admin API.
 const (
 	RoleSuperAdmin Role = "superadmin"
 	RoleAdmin      Role = "admin"
 	RoleModerator  Role = "moderator"
 	RoleUser       Role = "user"
+
+	// Organization roles are held through organization memberships rather than globally.
+	RoleOrgOwner  Role = "org-owner"
+	RoleOrgAdmin  Role = "org-admin"
+	RoleOrgMember Role = "org-member"
 )
 
 type Roles []Role
@@ -45,6 +56,63 @@ func (r *Roles) FromStrings(strings []string) {
 	}
 }
 
+var (
+	ErrDuplicateRoleCode = errors.New("duplicate role code")
+	ErrRoleInUse         = errors.New("role in use")
+
+	roleCodeRX = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+)
+
+// RoleDefinition is a role together with the permissions granted to it. System roles are
+// the built-in ones referenced from code; they cannot be renamed or deleted.
+type RoleDefinition struct {
+	Code        Role               `json:"code"`
+	Description string             `json:"description"`
+	System      bool               `json:"system"`
+	Permissions Permissions        `json:"permissions"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `json:"-"`
+}
+
+func (def *RoleDefinition) fromSQLCRole(r sqlc.Role) {
+	*def = RoleDefinition{
+		Code:        Role(r.Code),
+		Description: r.Description,
+		System:      r.System,
+		Permissions: Permissions{},
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+func ValidateRoleDefinition(v *validator.Validator, localizer *goi18n.Localizer, def *RoleDefinition) {
+	v.Check(validator.NotBlank(string(def.Code)), "code", i18n.LocalizeMessage(localizer, "RoleCodeMustBeProvided", nil))
+	v.Check(validator.MaxChars(string(def.Code), 32), "code", i18n.LocalizeMessage(localizer, "RoleCodeMustNotBeMoreThan32CharactersLong", nil))
+	v.Check(validator.Matches(string(def.Code), roleCodeRX), "code", i18n.LocalizeMessage(localizer, "RoleCodeMustBeValid", nil))
+	v.Check(validator.MaxChars(def.Description, 256), "description", i18n.LocalizeMessage(localizer, "RoleDescriptionMustNotBeMoreThan256CharactersLong", nil))
+}
+
+// CanGrantRole reports whether someone with the given roles and permissions may grant or
+// revoke a role carrying rolePermissions. Nobody may hand out more than they hold: the
+// grantor's permissions must be a superset of the role's. Superadmin bypasses permission
+// checks entirely, so only superadmins can grant it, and roles carrying admins:* permissions
+// additionally require admins:manage.
+func CanGrantRole(roles Roles, permissions Permissions, role Role, rolePermissions Permissions) bool {
+	if roles.Has(RoleSuperAdmin) {
+		return true
+	}
+
+	if role == RoleSuperAdmin {
+		return false
+	}
+
+	if rolePermissions.HasAny(PermAdminsView, PermAdminsManage) && !permissions.Has(PermAdminsManage) {
+		return false
+	}
+
+	return permissions.HasAll(rolePermissions...)
+}
+
 type RoleModel struct {
 	pool    *pgxpool.Pool
 	queries *sqlc.Queries
@@ -85,6 +153,152 @@ func (m RoleModel) InvalidateAllForUserCache(ctx context.Context, userID int64)
 	cache.TryInvalidate(ctx, m.cache, key)
 }
 
+// GetAll returns every role along with its permissions.
+func (m RoleModel) GetAll(ctx context.Context) ([]*RoleDefinition, error) {
+	rows, err := m.queries.GetAllRolesWithPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]*RoleDefinition, len(rows))
+	for i, row := range rows {
+		roles[i] = &RoleDefinition{
+			Code:        Role(row.Code),
+			Description: row.Description,
+			System:      row.System,
+			Permissions: Permissions{},
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+		}
+		roles[i].Permissions.FromStrings(row.Permissions)
+	}
+
+	return roles, nil
+}
+
+// Get returns the role along with its permissions.
+func (m RoleModel) Get(ctx context.Context, role Role) (*RoleDefinition, error) {
+	r, err := m.queries.GetRoleByCode(ctx, string(role))
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	stringPerms, err := m.queries.GetAllPermissionsForRoleCode(ctx, r.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	var def RoleDefinition
+	def.fromSQLCRole(r)
+	def.Permissions.FromStrings(stringPerms)
+	return &def, nil
+}
+
+// Insert creates a custom role with the permissions of the definition.
+func (m RoleModel) Insert(ctx context.Context, def *RoleDefinition) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	r, err := qtx.InsertRole(ctx, sqlc.InsertRoleParams{
+		Code:        string(def.Code),
+		Description: def.Description,
+	})
+	if err != nil {
+		return duplicateRoleCodeError(err)
+	}
+
+	if len(def.Permissions) > 0 {
+		err = qtx.AddPermissionsForRole(ctx, sqlc.AddPermissionsForRoleParams{
+			RoleCode:  r.Code,
+			PermCodes: def.Permissions.ToStrings(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	def.System = r.System
+	def.CreatedAt = r.CreatedAt
+	def.UpdatedAt = r.UpdatedAt
+	return nil
+}
+
+// Update renames and describes the role. Renaming changes the role of all of its users, so
+// their cached roles are dropped.
+func (m RoleModel) Update(ctx context.Context, role Role, def *RoleDefinition) error {
+	r, err := m.queries.UpdateRole(ctx, sqlc.UpdateRoleParams{
+		NewCode:     string(def.Code),
+		Description: def.Description,
+		Code:        string(role),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return duplicateRoleCodeError(err)
+		}
+	}
+
+	if def.Code != role {
+		cache.TryInvalidatePattern(ctx, m.cache, cache.KeyRolesPrefix+"*")
+	}
+
+	def.System = r.System
+	def.CreatedAt = r.CreatedAt
+	def.UpdatedAt = r.UpdatedAt
+	return nil
+}
+
+// Delete removes a custom role from everyone who has it globally. System roles are never
+// deleted, and neither are roles that organization members still hold.
+func (m RoleModel) Delete(ctx context.Context, role Role) error {
+	result, err := m.queries.DeleteRole(ctx, string(role))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			// Organization memberships keep their role, so it cannot go while in use.
+			if pgErr.Code == strconv.Itoa(23503) && strings.Contains(pgErr.ConstraintName, "organization_members_role_id_fkey") {
+				return ErrRoleInUse
+			}
+		}
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	cache.TryInvalidatePattern(ctx, m.cache, cache.KeyRolesPrefix+"*")
+	cache.TryInvalidatePattern(ctx, m.cache, cache.KeyPermissionsPrefix+"*")
+	return nil
+}
+
+func duplicateRoleCodeError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if pgErr.Code == strconv.Itoa(23505) && strings.Contains(pgErr.ConstraintName, "roles_code_key") {
+			return ErrDuplicateRoleCode
+		}
+	}
+	return err
+}
+
 func (m RoleModel) GetAllForUser(ctx context.Context, userID int64) (Roles, error) {
 	stringRoles, err := m.queries.GetAllRolesForUser(ctx, userID)
 	if err != nil {
@@ -111,6 +325,9 @@ func (m RoleModel) AddForUser(ctx context.Context, userID int64, roles Roles) er
 		}
 		return err
 	}
+
+	// The user's permissions come from their roles, so both cached entries are stale now.
+	cache.TryInvalidate(ctx, m.cache, cache.GenerateRolesKey(userID), cache.GeneratePermissionsKey(userID))
 	return nil
 }
 
@@ -128,6 +345,7 @@ func (m RoleModel) RemoveForUser(ctx context.Context, userID int64, roles Roles)
 		return ErrRecordNotFound
 	}
 
+	cache.TryInvalidate(ctx, m.cache, cache.GenerateRolesKey(userID), cache.GeneratePermissionsKey(userID))
 	return nil
 }
 
@@ -140,7 +358,13 @@ func (m RoleModel) ListUsersWithRole(ctx context.Context, role Role, filters Fil
 		WHERE r.code = $1
 		ORDER BY %s %s, u.id ASC
 		LIMIT $2 OFFSET $3`,
-		filters.sortColumn(sortColumnMapping{}),
+		filters.sortColumn(sortColumnMapping{
+			"id":         "u.id",
+			"created_at": "u.created_at",
+			"updated_at": "u.updated_at",
+			"full_name":  "u.full_name",
+			"email":      "u.email",
+		}),
 		filters.sortDirection(),
 	)
 
@@ -0,0 +1,104 @@
+package data_test
+
+import (
+	"testing"
+
+	"adcentra.ai/internal/data"
+)
+
+func TestCanGrantRole(t *testing.T) {
+	tests := []struct {
+		name            string
+		roles           data.Roles
+		permissions     data.Permissions
+		role            data.Role
+		rolePermissions data.Permissions
+		want            bool
+	}{
+		{
+			name:            "superadmin grants superadmin",
+			roles:           data.Roles{data.RoleSuperAdmin},
+			role:            data.RoleSuperAdmin,
+			rolePermissions: data.Permissions{data.PermAll},
+			want:            true,
+		},
+		{
+			name:            "superadmin grants anything",
+			roles:           data.Roles{data.RoleSuperAdmin},
+			role:            "custom",
+			rolePermissions: data.Permissions{data.PermAdminsManage, data.PermDebugVarsView},
+			want:            true,
+		},
+		{
+			name:        "only superadmins grant superadmin",
+			roles:       data.Roles{data.RoleAdmin},
+			permissions: data.Permissions{data.PermAll},
+			role:        data.RoleSuperAdmin,
+			want:        false,
+		},
+		{
+			name:            "subset of held permissions",
+			roles:           data.Roles{data.RoleAdmin},
+			permissions:     data.Permissions{data.PermUsersManage, data.PermRolesManage},
+			role:            data.RoleModerator,
+			rolePermissions: data.Permissions{data.PermUsersView},
+			want:            true,
+		},
+		{
+			name:            "permission not held",
+			roles:           data.Roles{data.RoleAdmin},
+			permissions:     data.Permissions{data.PermUsersManage, data.PermRolesManage},
+			role:            data.RoleModerator,
+			rolePermissions: data.Permissions{data.PermUsersView, data.PermDebugVarsView},
+			want:            false,
+		},
+		{
+			name:            "held through a wildcard",
+			roles:           data.Roles{data.RoleAdmin},
+			permissions:     data.Permissions{"users:*", data.PermRolesManage},
+			role:            data.RoleModerator,
+			rolePermissions: data.Permissions{data.PermUsersManage},
+			want:            true,
+		},
+		{
+			name:            "role with a wildcard needs the wildcard",
+			roles:           data.Roles{data.RoleAdmin},
+			permissions:     data.Permissions{data.PermUsersView, data.PermUsersManage},
+			role:            "custom",
+			rolePermissions: data.Permissions{"users:*"},
+			want:            false,
+		},
+		{
+			name:            "admins permissions need admins:manage",
+			roles:           data.Roles{data.RoleAdmin},
+			permissions:     data.Permissions{data.PermAdminsView, data.PermRolesManage},
+			role:            "custom",
+			rolePermissions: data.Permissions{data.PermAdminsView},
+			want:            false,
+		},
+		{
+			name:            "admins permissions with admins:manage",
+			roles:           data.Roles{data.RoleAdmin},
+			permissions:     data.Permissions{data.PermAdminsManage, data.PermRolesManage},
+			role:            "custom",
+			rolePermissions: data.Permissions{data.PermAdminsView},
+			want:            true,
+		},
+		{
+			name:            "role without permissions",
+			roles:           data.Roles{data.RoleUser},
+			role:            data.RoleUser,
+			rolePermissions: data.Permissions{},
+			want:            true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := data.CanGrantRole(tt.roles, tt.permissions, tt.role, tt.rolePermissions)
+			if got != tt.want {
+				t.Errorf("CanGrantRole() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,16 +7,79 @@ import (
 	"time"
 
 	"adcentra.ai/internal/db/sqlc"
+	i18n "adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/validator"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
+	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
+// Session is a device the user is logged in from. Refresh and authentication tokens hang off
+// a session, so revoking a session logs the device out. For an authenticated request, the
+// session also carries the user, the presented token and the user's roles and permissions.
 type Session struct {
-	User          *User
-	Token         *Token
-	Roles         Roles
-	PermissionMap map[PermCode]bool
+	ID         int64              `json:"id"`
+	UserID     int64              `json:"-"`
+	DeviceName string             `json:"device_name"`
+	UserAgent  string             `json:"user_agent"`
+	IP         string             `json:"ip"`
+	LastSeenAt pgtype.Timestamptz `json:"last_seen_at"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt  pgtype.Timestamptz `json:"-"`
+	Current    bool               `json:"current"`
+	// AuthenticatedAt is when the user last proved their identity in this session, by
+	// logging in or re-authenticating. Refreshing tokens doesn't count.
+	AuthenticatedAt pgtype.Timestamptz `json:"authenticated_at"`
+
+	User          *User             `json:"-"`
+	Token         *Token            `json:"-"`
+	Roles         Roles             `json:"-"`
+	PermissionMap map[PermCode]bool `json:"-"`
+	// Stateless is set when the session was built from a signed access token. Token is nil
+	// then and User only holds the fields carried in the token.
+	Stateless bool `json:"-"`
+	// APIKey is set when the request was authenticated with an API key instead of a token.
+	APIKey *APIKey `json:"-"`
+	// OrganizationID is the active organization named by the request, if any. Membership
+	// holds the user's role in it once requireOrgPermissions has checked it.
+	OrganizationID int64       `json:"-"`
+	Membership     *Membership `json:"-"`
+}
+
+// Permissions returns the permissions the request was authenticated with.
+func (session *Session) Permissions() Permissions {
+	permissions := make(Permissions, 0, len(session.PermissionMap))
+	for code, ok := range session.PermissionMap {
+		if ok {
+			permissions = append(permissions, code)
+		}
+	}
+	return permissions
+}
+
+// HasPermission reports whether the request was authenticated with a permission granting
+// code, directly, through a wildcard or through an implied permission.
+func (session *Session) HasPermission(code PermCode) bool {
+	if session.PermissionMap[code] {
+		return true
+	}
+	return session.Permissions().Has(code)
+}
+
+func (session *Session) fromSQLCSession(s sqlc.Session) {
+	*session = Session{
+		ID:         s.ID,
+		UserID:     s.UserID,
+		DeviceName: s.DeviceName,
+		UserAgent:  s.UserAgent,
+		IP:         s.Ip,
+		LastSeenAt: s.LastSeenAt,
+		CreatedAt:  s.CreatedAt,
+		UpdatedAt:  s.UpdatedAt,
+
+		AuthenticatedAt: s.AuthenticatedAt,
+	}
 }
 
 type SessionModel struct {
@@ -24,6 +87,25 @@ type SessionModel struct {
 	queries *sqlc.Queries
 }
 
+// New records a device session for the user, who last proved who they are at
+// authenticatedAt.
+func (m SessionModel) New(ctx context.Context, userID int64, deviceName, userAgent, ip string, authenticatedAt time.Time) (*Session, error) {
+	s, err := m.queries.InsertSession(ctx, sqlc.InsertSessionParams{
+		UserID:          userID,
+		DeviceName:      deviceName,
+		UserAgent:       userAgent,
+		Ip:              ip,
+		AuthenticatedAt: pgtype.Timestamptz{Time: authenticatedAt, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	session.fromSQLCSession(s)
+	return &session, nil
+}
+
 func (m SessionModel) GetForToken(ctx context.Context, tokenPlainText string) (*Session, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlainText))
 
@@ -38,6 +120,8 @@ func (m SessionModel) GetForToken(ctx context.Context, tokenPlainText string) (*
 	var token Token
 	token.fromSQLCToken(s.Token)
 	var session Session
+	session.ID = token.SessionID.Int64
+	session.UserID = user.ID
 	session.User = &user
 	session.Token = &token
 	session.Token.Plaintext = tokenPlainText
@@ -53,3 +137,121 @@ func (m SessionModel) GetForToken(ctx context.Context, tokenPlainText string) (*
 
 	return &session, nil
 }
+
+func (m SessionModel) GetAllForUser(ctx context.Context, userID int64) ([]*Session, error) {
+	rows, err := m.queries.GetAllSessionsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, len(rows))
+	for i, s := range rows {
+		sessions[i] = &Session{}
+		sessions[i].fromSQLCSession(s)
+	}
+
+	return sessions, nil
+}
+
+// Touch records that the session was just used from the given user agent and IP address.
+func (m SessionModel) Touch(ctx context.Context, id int64, userAgent, ip string) error {
+	return m.queries.TouchSession(ctx, sqlc.TouchSessionParams{
+		ID:        id,
+		UserAgent: userAgent,
+		Ip:        ip,
+	})
+}
+
+// GetAuthenticatedAt returns when the user last proved their identity in the session.
+func (m SessionModel) GetAuthenticatedAt(ctx context.Context, id, userID int64) (time.Time, error) {
+	authenticatedAt, err := m.queries.GetSessionAuthenticatedAt(ctx, sqlc.GetSessionAuthenticatedAtParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return time.Time{}, ErrRecordNotFound
+		default:
+			return time.Time{}, err
+		}
+	}
+
+	return authenticatedAt.Time, nil
+}
+
+// Reauthenticate records that the user just proved their identity again in the session, and
+// returns the time it was recorded at.
+func (m SessionModel) Reauthenticate(ctx context.Context, id, userID int64) (time.Time, error) {
+	authenticatedAt, err := m.queries.ReauthenticateSession(ctx, sqlc.ReauthenticateSessionParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return time.Time{}, ErrRecordNotFound
+		default:
+			return time.Time{}, err
+		}
+	}
+
+	return authenticatedAt.Time, nil
+}
+
+// Delete revokes the session of the user along with all tokens issued for it.
+func (m SessionModel) Delete(ctx context.Context, id, userID int64) error {
+	result, err := m.queries.DeleteSession(ctx, sqlc.DeleteSessionParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteOrphaned removes sessions older than the given age whose tokens have all expired
+// or been revoked.
+func (m SessionModel) DeleteOrphaned(ctx context.Context, olderThan time.Duration) error {
+	return m.queries.DeleteOrphanedSessions(ctx, pgtype.Timestamptz{Time: time.Now().Add(-olderThan), Valid: true})
+}
+
+// DeleteAllForUserExcept signs the user out everywhere but in the given session. Tokens
+// issued before device sessions existed are revoked as well. Pass 0 to keep no session.
+func (m SessionModel) DeleteAllForUserExcept(ctx context.Context, userID, exceptID int64) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	err = qtx.DeleteAllSessionsForUserExcept(ctx, sqlc.DeleteAllSessionsForUserExceptParams{
+		UserID:   userID,
+		ExceptID: exceptID,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = qtx.DeleteSessionlessTokensForUser(ctx, sqlc.DeleteSessionlessTokensForUserParams{
+		UserID: userID,
+		Scopes: []string{ScopeRefresh, ScopeAuthentication},
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func ValidateDeviceName(v *validator.Validator, localizer *goi18n.Localizer, deviceName string) {
+	v.Check(validator.MaxChars(deviceName, 64), "device_name", i18n.LocalizeMessage(localizer, "DeviceNameMustNotBeMoreThan64CharactersLong", nil))
+}
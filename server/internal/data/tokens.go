@@ -4,11 +4,13 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"errors"
 	"time"
 
 	"adcentra.ai/internal/db/sqlc"
 	i18n "adcentra.ai/internal/i18n"
 	"adcentra.ai/internal/validator"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
@@ -19,6 +21,10 @@ const (
 	ScopeAuthentication = "authentication"
 	ScopePasswordReset  = "password-reset"
 	ScopeRefresh        = "refresh"
+	ScopeMFAChallenge   = "mfa-challenge"
+	ScopeMagicLink      = "magic-link"
+	ScopeEmailChange    = "email-change"
+	ScopeInvitation     = "invitation"
 )
 
 type Token struct {
@@ -29,6 +35,15 @@ type Token struct {
 	UserID    int64              `json:"-"`
 	Expiry    pgtype.Timestamptz `json:"expiry"`
 	Scope     string             `json:"-"`
+	// Family groups the refresh and authentication tokens issued from a single login.
+	// Every rotation of the refresh token stays in the same family, so that a replayed
+	// (already rotated) refresh token can revoke the whole chain.
+	Family    []byte             `json:"-"`
+	RotatedAt pgtype.Timestamptz `json:"-"`
+	SessionID pgtype.Int8        `json:"-"`
+	// Email is the pending address that an email-change token confirms, or the invited
+	// address of an invitation token.
+	Email pgtype.Text `json:"-"`
 }
 
 func (token *Token) fromSQLCToken(t sqlc.Token) {
@@ -37,13 +52,24 @@ func (token *Token) fromSQLCToken(t sqlc.Token) {
 			CreatedAt: t.CreatedAt,
 			UpdatedAt: t.UpdatedAt,
 		},
-		Hash:   t.Hash,
-		UserID: t.UserID,
-		Expiry: t.Expiry,
-		Scope:  t.Scope,
+		Hash:      t.Hash,
+		UserID:    t.UserID,
+		Expiry:    t.Expiry,
+		Scope:     t.Scope,
+		Family:    t.Family,
+		RotatedAt: t.RotatedAt,
+		SessionID: t.SessionID,
+		Email:     t.Email,
 	}
 }
 
+// NewTokenFamily returns a random identifier for a new refresh token family.
+func NewTokenFamily() []byte {
+	family := make([]byte, 16)
+	rand.Read(family)
+	return family
+}
+
 func generateToken(userID int64, ttl time.Duration, scope string) *Token {
 	// Set the Plaintext field to be a random token generated by rand.Text()
 	token := &Token{
@@ -80,12 +106,36 @@ func (m TokenModel) New(ctx context.Context, userID int64, ttl time.Duration, sc
 	return token, err
 }
 
+// NewForSession issues a token of the given scope for a device session. The token joins the
+// given refresh token family.
+func (m TokenModel) NewForSession(ctx context.Context, session *Session, ttl time.Duration, scope string, family []byte) (*Token, error) {
+	token := generateToken(session.UserID, ttl, scope)
+	token.Family = family
+	token.SessionID = pgtype.Int8{Int64: session.ID, Valid: true}
+
+	err := m.Insert(ctx, token)
+	return token, err
+}
+
+// NewForEmailChange issues a token confirming that the user wants to change their email
+// to the given address.
+func (m TokenModel) NewForEmailChange(ctx context.Context, userID int64, ttl time.Duration, email string) (*Token, error) {
+	token := generateToken(userID, ttl, ScopeEmailChange)
+	token.Email = pgtype.Text{String: email, Valid: true}
+
+	err := m.Insert(ctx, token)
+	return token, err
+}
+
 func (m TokenModel) Insert(ctx context.Context, token *Token) error {
 	t, err := m.queries.InsertToken(ctx, sqlc.InsertTokenParams{
-		Hash:   token.Hash,
-		UserID: token.UserID,
-		Expiry: token.Expiry,
-		Scope:  token.Scope,
+		Hash:      token.Hash,
+		UserID:    token.UserID,
+		Expiry:    token.Expiry,
+		Scope:     token.Scope,
+		Family:    token.Family,
+		SessionID: token.SessionID,
+		Email:     token.Email,
 	})
 	if err != nil {
 		return err
@@ -96,6 +146,46 @@ func (m TokenModel) Insert(ctx context.Context, token *Token) error {
 	return nil
 }
 
+// GetByPlaintext returns the unexpired token of the given scope, including refresh tokens
+// that have already been rotated out. Callers must check RotatedAt themselves.
+func (m TokenModel) GetByPlaintext(ctx context.Context, scope, tokenPlaintext string) (*Token, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	t, err := m.queries.GetTokenByHash(ctx, sqlc.GetTokenByHashParams{
+		Hash:              tokenHash[:],
+		Scope:             scope,
+		ExpiryGreaterThan: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	var token Token
+	token.fromSQLCToken(t)
+	token.Plaintext = tokenPlaintext
+	return &token, nil
+}
+
+// MarkRotated flags the token as rotated out. It returns ErrRecordNotFound if the token
+// does not exist or has already been rotated, e.g. by a concurrent refresh.
+func (m TokenModel) MarkRotated(ctx context.Context, hash []byte) error {
+	result, err := m.queries.MarkTokenRotated(ctx, hash)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
 func (m TokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
 	return m.queries.DeleteAllForUser(ctx, sqlc.DeleteAllForUserParams{
 		Scope:  scope,
@@ -115,6 +205,11 @@ func (m TokenModel) DeleteByHash(ctx context.Context, hash []byte) error {
 	return m.queries.DeleteByHash(ctx, hash)
 }
 
+// DeleteFamily revokes every refresh and authentication token derived from the same login.
+func (m TokenModel) DeleteFamily(ctx context.Context, family []byte) error {
+	return m.queries.DeleteFamily(ctx, family)
+}
+
 func (m TokenModel) DeleteExpiredTokens(ctx context.Context) error {
 	return m.queries.DeleteExpiredTokens(ctx)
 }
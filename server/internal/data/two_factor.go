@@ -0,0 +1,275 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"adcentra.ai/internal/db/sqlc"
+	"adcentra.ai/internal/hasher"
+	i18n "adcentra.ai/internal/i18n"
+	"adcentra.ai/internal/totp"
+	"adcentra.ai/internal/validator"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+)
+
+type TwoFactor struct {
+	Base
+
+	UserID       int64
+	Secret       []byte
+	EnabledAt    pgtype.Timestamptz
+	LastUsedStep int64
+}
+
+func (tf *TwoFactor) fromSQLCUserTotp(t sqlc.UserTotp) {
+	*tf = TwoFactor{
+		Base: Base{
+			CreatedAt: t.CreatedAt,
+			UpdatedAt: t.UpdatedAt,
+		},
+		UserID:       t.UserID,
+		Secret:       t.Secret,
+		EnabledAt:    t.EnabledAt,
+		LastUsedStep: t.LastUsedStep,
+	}
+}
+
+// Enabled reports whether the enrollment has been confirmed with a valid code.
+func (tf *TwoFactor) Enabled() bool {
+	return tf.EnabledAt.Valid
+}
+
+func ValidateTwoFactorCode(v *validator.Validator, localizer *goi18n.Localizer, code string) {
+	v.Check(validator.NotBlank(code), "code", i18n.LocalizeMessage(localizer, "TwoFactorCodeMustBeProvided", nil))
+	v.Check(len(code) == totp.Digits && validator.Matches(code, validator.NumericRX), "code", i18n.LocalizeMessage(localizer, "TwoFactorCodeMustBe6Digits", nil))
+}
+
+// Recovery codes are formatted as two groups of five base32 characters, e.g. "ABCDE-FGH23".
+// Hyphens, spaces and letter case are ignored when they are entered.
+func generateRecoveryCode() string {
+	code := rand.Text()[:10]
+	return code[:5] + "-" + code[5:]
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+type TwoFactorModel struct {
+	pool    *pgxpool.Pool
+	queries *sqlc.Queries
+	// hasher hashes recovery codes like passwords, salted, so they are checked one by one.
+	hasher *hasher.Hasher
+}
+
+func (m TwoFactorModel) GetForUser(ctx context.Context, userID int64) (*TwoFactor, error) {
+	t, err := m.queries.GetTOTPForUser(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	var tf TwoFactor
+	tf.fromSQLCUserTotp(t)
+	return &tf, nil
+}
+
+// IsEnabledForUser reports whether the user has to provide a second factor when logging in.
+func (m TwoFactorModel) IsEnabledForUser(ctx context.Context, userID int64) (bool, error) {
+	tf, err := m.GetForUser(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return tf.Enabled(), nil
+}
+
+// Enroll generates a new secret for the user. Two-factor authentication stays disabled until
+// the enrollment is confirmed with Enable. Enrolling again replaces a pending secret.
+func (m TwoFactorModel) Enroll(ctx context.Context, userID int64) (*TwoFactor, error) {
+	tf := &TwoFactor{
+		UserID: userID,
+		Secret: totp.GenerateSecret(),
+	}
+
+	err := m.queries.UpsertTOTPSecret(ctx, sqlc.UpsertTOTPSecretParams{
+		UserID: userID,
+		Secret: tf.Secret,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tf, nil
+}
+
+// Verify checks a TOTP code for the user. A code is accepted only once, so that an intercepted
+// code cannot be replayed within its validity window.
+func (m TwoFactorModel) Verify(ctx context.Context, tf *TwoFactor, code string) error {
+	step, ok := totp.Validate(tf.Secret, code, time.Now(), tf.LastUsedStep)
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	result, err := m.queries.UseTOTPStep(ctx, sqlc.UseTOTPStepParams{
+		Step:   step,
+		UserID: tf.UserID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+
+	tf.LastUsedStep = step
+	return nil
+}
+
+// UseRecoveryCode consumes one of the user's recovery codes.
+func (m TwoFactorModel) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	hashes, err := m.queries.GetAllRecoveryCodeHashesForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	for _, hash := range hashes {
+		match, _, err := m.hasher.Verify(normalized, hash)
+		if err != nil {
+			return err
+		}
+		if !match {
+			continue
+		}
+
+		// A concurrent request may have used the same code first.
+		result, err := m.queries.DeleteRecoveryCode(ctx, sqlc.DeleteRecoveryCodeParams{
+			UserID: userID,
+			Hash:   hash,
+		})
+		if err != nil {
+			return err
+		}
+
+		if result.RowsAffected() == 0 {
+			return ErrInvalidTwoFactorCode
+		}
+
+		return nil
+	}
+
+	return ErrInvalidTwoFactorCode
+}
+
+// Enable confirms the enrollment and returns a fresh set of recovery codes. The plaintext codes
+// are only available here; just their hashes are stored.
+func (m TwoFactorModel) Enable(ctx context.Context, userID int64) ([]string, error) {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	err = qtx.EnableTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := m.replaceRecoveryCodes(ctx, qtx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, tx.Commit(ctx)
+}
+
+// RegenerateRecoveryCodes invalidates all existing recovery codes of the user and returns new ones.
+func (m TwoFactorModel) RegenerateRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	codes, err := m.replaceRecoveryCodes(ctx, m.queries.WithTx(tx), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, tx.Commit(ctx)
+}
+
+func (m TwoFactorModel) replaceRecoveryCodes(ctx context.Context, qtx *sqlc.Queries, userID int64) ([]string, error) {
+	err := qtx.DeleteAllRecoveryCodesForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([][]byte, recoveryCodeCount)
+	for i := range codes {
+		codes[i] = generateRecoveryCode()
+		hashes[i], err = m.hasher.Hash(normalizeRecoveryCode(codes[i]))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = qtx.InsertRecoveryCodes(ctx, sqlc.InsertRecoveryCodesParams{
+		Hashes: hashes,
+		UserID: userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable removes the secret and all recovery codes of the user.
+func (m TwoFactorModel) Disable(ctx context.Context, userID int64) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	err = qtx.DeleteTOTPForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.DeleteAllRecoveryCodesForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -5,11 +5,14 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
+	"adcentra.ai/internal/cache"
 	"adcentra.ai/internal/db/sqlc"
+	"adcentra.ai/internal/hasher"
 	i18n "adcentra.ai/internal/i18n"
 	"adcentra.ai/internal/validator"
 	"github.com/jackc/pgx/v5"
@@ -17,7 +20,6 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -35,6 +37,12 @@ type User struct {
 	Activated       bool               `json:"activated"`
 	Version         int32              `json:"-"`
 	LastLoginAt     pgtype.Timestamptz `json:"last_login_at,omitzero"`
+	// DeletionRequestedAt is set while the account is scheduled for deletion. Logging in
+	// again before the grace period ends cancels the deletion.
+	DeletionRequestedAt pgtype.Timestamptz `json:"deletion_requested_at,omitzero"`
+	// Disabled is set by administrators to keep the user out, whether or not the account was
+	// activated.
+	Disabled bool `json:"disabled"`
 }
 
 func (user *User) fromSQLCUser(u sqlc.User) {
@@ -43,14 +51,16 @@ func (user *User) fromSQLCUser(u sqlc.User) {
 			CreatedAt: u.CreatedAt,
 			UpdatedAt: u.UpdatedAt,
 		},
-		ID:              u.ID,
-		FullName:        u.FullName,
-		Email:           u.Email,
-		Password:        password{hash: u.PasswordHash},
-		ProfileImageURL: u.ProfileImageUrl,
-		Activated:       u.Activated,
-		Version:         u.Version,
-		LastLoginAt:     u.LastLoginAt,
+		ID:                  u.ID,
+		FullName:            u.FullName,
+		Email:               u.Email,
+		Password:            password{hash: u.PasswordHash},
+		ProfileImageURL:     u.ProfileImageUrl,
+		Activated:           u.Activated,
+		Version:             u.Version,
+		LastLoginAt:         u.LastLoginAt,
+		DeletionRequestedAt: u.DeletionRequestedAt,
+		Disabled:            u.Disabled,
 	}
 }
 
@@ -59,43 +69,18 @@ type password struct {
 	// password not provided and password being empty string "".
 	plaintext *string
 	hash      []byte
+	// maxBytes is the length of the longest password the hasher that hashed the plaintext
+	// accepts, or 0 if there is no limit.
+	maxBytes int
+	// outdated is set by a successful PasswordMatches when the hash was made with another
+	// algorithm or other parameters than the hasher uses now.
+	outdated bool
 }
 
-func (p *password) Set(plaintextPassword string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
-	if err != nil {
-		return err
-	}
-
-	p.plaintext = &plaintextPassword
-	p.hash = hash
-
-	return nil
-}
-
-func (p *password) SetOAuthPasswordPlaceholder() {
-	// Create a valid bcrypt hash that won't match any password
-	p.Set(rand.Text())
-	p.plaintext = nil
-}
-
-func (p *password) Matches(plaintextPassword string) (bool, error) {
-	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
-	if err != nil {
-		switch {
-		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
-			return false, nil
-		default:
-			return false, err
-		}
-	}
-	return true, err
-}
-
-// When a user does not exist, DummyMatch() is used to prevent timing attacks on username/email.
-func (p *password) DummyMatches(plaintextPassword string) {
-	p.hash = []byte("$2a$12$otsImdHOADAlVBJldO3tneviC3T2SZ4noMGbf3ZnjA1XxwF0bJuwa")
-	bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
+// Outdated reports whether the password matched by PasswordMatches should be hashed again,
+// with SetPassword, to bring its hash up to the current algorithm and parameters.
+func (p *password) Outdated() bool {
+	return p.outdated
 }
 
 func ValidateEmail(v *validator.Validator, localizer *goi18n.Localizer, email string) {
@@ -103,14 +88,23 @@ func ValidateEmail(v *validator.Validator, localizer *goi18n.Localizer, email st
 	v.Check(validator.Matches(email, validator.EmailRX), "email", i18n.LocalizeMessage(localizer, "EmailMustBeValid", nil))
 }
 
-func ValidatePasswordPlaintext(v *validator.Validator, localizer *goi18n.Localizer, password string) {
+// ValidatePasswordPlaintext checks the password against the password rules. The related
+// values, such as the user's email address and full name, are ones the password must not be
+// built from. Whether the password was breached is checked separately, since it may take a
+// network request.
+func ValidatePasswordPlaintext(v *validator.Validator, localizer *goi18n.Localizer, password string, maxBytes int, related ...string) {
 	v.Check(validator.NotBlank(password), "password", i18n.LocalizeMessage(localizer, "PasswordMustBeProvided", nil))
 	v.Check(validator.MinChars(password, 8), "password", i18n.LocalizeMessage(localizer, "PasswordMustBeAtLeast8CharactersLong", nil))
-	v.Check(validator.MaxChars(password, 72), "password", i18n.LocalizeMessage(localizer, "PasswordMustNotBeMoreThan72CharactersLong", nil))
+	v.Check(validator.MaxChars(password, 128), "password", i18n.LocalizeMessage(localizer, "PasswordMustNotBeMoreThan128CharactersLong", nil))
+	if maxBytes > 0 {
+		v.Check(validator.MaxBytes(password, maxBytes), "password", i18n.LocalizeMessage(localizer, "PasswordTooLong", map[string]any{"MaxBytes": maxBytes}))
+	}
 	v.Check(validator.Matches(password, validator.HasLowerRX), "password", i18n.LocalizeMessage(localizer, "PasswordMustHaveAtLeast1LowerCaseCharacter", nil))
 	v.Check(validator.Matches(password, validator.HasUpperRX), "password", i18n.LocalizeMessage(localizer, "PasswordMustHaveAtLeast1UpperCaseCharacter", nil))
 	v.Check(validator.Matches(password, validator.HasSpecialRX), "password", i18n.LocalizeMessage(localizer, "PasswordMustHaveAtLeast1SpecialCharacter", nil))
 	v.Check(validator.Matches(password, validator.HasDigitRX), "password", i18n.LocalizeMessage(localizer, "PasswordMustHaveAtLeast1NumericCharacter", nil))
+	v.Check(!validator.CommonPassword(password), "password", i18n.LocalizeMessage(localizer, "PasswordIsTooCommon", nil))
+	v.Check(!validator.SimilarTo(password, related...), "password", i18n.LocalizeMessage(localizer, "PasswordIsTooSimilarToAccount", nil))
 }
 
 func ValidateUser(v *validator.Validator, localizer *goi18n.Localizer, user *User) {
@@ -119,8 +113,13 @@ func ValidateUser(v *validator.Validator, localizer *goi18n.Localizer, user *Use
 
 	ValidateEmail(v, localizer, user.Email)
 
+	if user.ProfileImageURL.Valid {
+		v.Check(validator.ValidURL(user.ProfileImageURL.String), "profile_image_url", i18n.LocalizeMessage(localizer, "ProfileImageURLMustBeValid", nil))
+		v.Check(validator.MaxBytes(user.ProfileImageURL.String, 2048), "profile_image_url", i18n.LocalizeMessage(localizer, "ProfileImageURLMustNotBeMoreThan2048BytesLong", nil))
+	}
+
 	if user.Password.plaintext != nil {
-		ValidatePasswordPlaintext(v, localizer, *user.Password.plaintext)
+		ValidatePasswordPlaintext(v, localizer, *user.Password.plaintext, user.Password.maxBytes, user.Email, user.FullName)
 	}
 
 	// If the password hash is ever nil, this will be due to a logic error in our
@@ -139,13 +138,105 @@ func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
 
+// UserStatus tells whether an account may still be used. Signed access tokens are checked
+// against it, since they are otherwise valid until they expire whatever becomes of the account.
+type UserStatus struct {
+	DeletionRequested bool `json:"deletion_requested"`
+	Disabled          bool `json:"disabled"`
+}
+
+// Active reports whether requests may be made on behalf of the user.
+func (s UserStatus) Active() bool {
+	return !s.DeletionRequested && !s.Disabled
+}
+
+// Status returns the status of the user's account.
+func (user *User) Status() UserStatus {
+	return UserStatus{
+		DeletionRequested: user.DeletionRequestedAt.Valid,
+		Disabled:          user.Disabled,
+	}
+}
+
 type UserModel struct {
 	pool    *pgxpool.Pool
 	queries *sqlc.Queries
+	cache   cache.Cache
+	hasher  *hasher.Hasher
+}
+
+// SetPassword hashes the plaintext password for the user.
+func (m UserModel) SetPassword(user *User, plaintextPassword string) error {
+	hash, err := m.hasher.Hash(plaintextPassword)
+	if err != nil {
+		return err
+	}
+
+	user.Password = password{
+		plaintext: &plaintextPassword,
+		hash:      hash,
+		maxBytes:  m.hasher.MaxPasswordBytes(),
+	}
+
+	return nil
+}
+
+// SetOAuthPasswordPlaceholder gives the user a valid hash that won't match any password.
+func (m UserModel) SetOAuthPasswordPlaceholder(user *User) {
+	m.SetPassword(user, rand.Text())
+	user.Password.plaintext = nil
+}
+
+// PasswordMatches reports whether the plaintext password matches the user's.
+func (m UserModel) PasswordMatches(user *User, plaintextPassword string) (bool, error) {
+	match, outdated, err := m.hasher.Verify(plaintextPassword, user.Password.hash)
+	if err != nil {
+		return false, err
+	}
+
+	user.Password.outdated = outdated
+	return match, nil
+}
+
+// When a user does not exist, DummyPasswordMatches() is used to prevent timing attacks on
+// username/email.
+func (m UserModel) DummyPasswordMatches(plaintextPassword string) {
+	m.hasher.DummyVerify(plaintextPassword)
+}
+
+// MaxPasswordBytes returns the length of the longest password that can be set, or 0 if
+// there is no limit.
+func (m UserModel) MaxPasswordBytes() int {
+	return m.hasher.MaxPasswordBytes()
+}
+
+const UserStatusCacheTTL = 5 * time.Minute
+
+// GetStatus returns the status of the user's account, going through the cache. Changes to
+// the account invalidate the cached status.
+func (m UserModel) GetStatus(ctx context.Context, id int64) (UserStatus, error) {
+	key := cache.GenerateUserStatusKey(id)
+	var status UserStatus
+	if cache.TryGet(ctx, m.cache, key, &status) {
+		return status, nil
+	}
+
+	user, err := m.GetByID(ctx, id)
+	if err != nil {
+		return UserStatus{}, err
+	}
+
+	status = user.Status()
+	cache.TrySet(ctx, m.cache, key, status, UserStatusCacheTTL)
+	return status, nil
 }
 
 func (m UserModel) Insert(ctx context.Context, user *User) error {
-	insertedUser, err := m.queries.InsertUser(ctx, sqlc.InsertUserParams{
+	return insertUser(ctx, m.queries, user)
+}
+
+func insertUser(ctx context.Context, q *sqlc.Queries, user *User) error {
+	insertedUser, err := q.InsertUser(ctx, sqlc.InsertUserParams{
 		FullName:        user.FullName,
 		Email:           user.Email,
 		ProfileImageUrl: user.ProfileImageURL,
@@ -203,6 +294,65 @@ func (m UserModel) GetByEmail(ctx context.Context, email string) (*User, error)
 	return &user, nil
 }
 
+// GetAll lists users whose email or full name contains search, optionally only those with
+// the given activation status. The search is matched literally and case-insensitively, so
+// "%", "_" and "\" in it are not taken for LIKE wildcards.
+func (m UserModel) GetAll(ctx context.Context, search string, activated *bool, filters Filters) ([]*User, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), u.id, u.full_name, u.email, u.password_hash, u.profile_image_url, u.activated, u.last_login_at, u.version, u.created_at, u.updated_at, u.deletion_requested_at, u.disabled
+		FROM users u
+		WHERE ($1 = '' OR strpos(lower(u.email), lower($1)) > 0 OR strpos(lower(u.full_name), lower($1)) > 0)
+		AND ($2::bool IS NULL OR u.activated = $2)
+		ORDER BY %s %s NULLS LAST, u.id ASC
+		LIMIT $3 OFFSET $4`,
+		filters.sortColumn(sortColumnMapping{
+			"id":            "u.id",
+			"full_name":     "u.full_name",
+			"email":         "u.email",
+			"created_at":    "u.created_at",
+			"last_login_at": "u.last_login_at",
+		}),
+		filters.sortDirection(),
+	)
+
+	rows, err := m.pool.Query(ctx, query, search, activated, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, getEmptyMetadata(filters.Page, filters.PageSize), err
+	}
+
+	totalRecords := 0
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*User, error) {
+		var u sqlc.User
+		err := row.Scan(
+			&totalRecords,
+			&u.ID,
+			&u.FullName,
+			&u.Email,
+			&u.PasswordHash,
+			&u.ProfileImageUrl,
+			&u.Activated,
+			&u.LastLoginAt,
+			&u.Version,
+			&u.CreatedAt,
+			&u.UpdatedAt,
+			&u.DeletionRequestedAt,
+			&u.Disabled,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		var user User
+		user.fromSQLCUser(u)
+		return &user, nil
+	})
+	if err != nil {
+		return nil, getEmptyMetadata(filters.Page, filters.PageSize), err
+	}
+
+	return users, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
 func (m UserModel) GetForToken(ctx context.Context, scope, tokenPlaintext string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
@@ -226,16 +376,28 @@ func (m UserModel) GetForToken(ctx context.Context, scope, tokenPlaintext string
 }
 
 func (m UserModel) Update(ctx context.Context, user *User) error {
-	version, err := m.queries.Update(ctx, sqlc.UpdateParams{
-		FullName:        user.FullName,
-		Email:           user.Email,
-		ProfileImageUrl: user.ProfileImageURL,
-		PasswordHash:    user.Password.hash,
-		Activated:       user.Activated,
-		UpdatedAt:       pgtype.Timestamptz{Time: time.Now(), Valid: true},
-		LastLoginAt:     user.LastLoginAt,
-		Version:         user.Version,
-		ID:              user.ID,
+	err := m.update(ctx, m.queries, user)
+	if err != nil {
+		return err
+	}
+
+	cache.TryInvalidate(ctx, m.cache, cache.GenerateUserStatusKey(user.ID))
+	return nil
+}
+
+func (m UserModel) update(ctx context.Context, q *sqlc.Queries, user *User) error {
+	version, err := q.Update(ctx, sqlc.UpdateParams{
+		FullName:            user.FullName,
+		Email:               user.Email,
+		ProfileImageUrl:     user.ProfileImageURL,
+		PasswordHash:        user.Password.hash,
+		Activated:           user.Activated,
+		UpdatedAt:           pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		LastLoginAt:         user.LastLoginAt,
+		Version:             user.Version,
+		ID:                  user.ID,
+		DeletionRequestedAt: user.DeletionRequestedAt,
+		Disabled:            user.Disabled,
 	})
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -253,3 +415,139 @@ func (m UserModel) Update(ctx context.Context, user *User) error {
 
 	return nil
 }
+
+// Claim activates an account whose owner has just proven the email address some other way
+// than with the activation token, such as through an OpenID Connect provider or a magic link.
+// Until then anyone could have registered the address, so whatever they set up is discarded:
+// the password is replaced with one nobody knows, and two-factor authentication, sessions,
+// tokens and API keys are removed. The owner can set a password by resetting it.
+func (m UserModel) Claim(ctx context.Context, user *User) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	m.SetOAuthPasswordPlaceholder(user)
+	user.Activated = true
+	err = m.update(ctx, qtx, user)
+	if err != nil {
+		return err
+	}
+
+	err = revokeAllAccess(ctx, qtx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.DeleteTOTPForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.DeleteAllRecoveryCodesForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// MarkForDeletion schedules the account for deletion and signs the user out everywhere by
+// revoking all of their sessions, tokens and API keys.
+func (m UserModel) MarkForDeletion(ctx context.Context, user *User) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	user.DeletionRequestedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	err = m.update(ctx, qtx, user)
+	if err != nil {
+		return err
+	}
+
+	err = revokeAllAccess(ctx, qtx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	cache.TryInvalidate(ctx, m.cache, cache.GenerateUserStatusKey(user.ID))
+	return nil
+}
+
+// Disable keeps the user out of their account until an administrator enables it again, and
+// signs them out everywhere by revoking all of their sessions, tokens and API keys.
+func (m UserModel) Disable(ctx context.Context, user *User) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := m.queries.WithTx(tx)
+
+	user.Disabled = true
+	err = m.update(ctx, qtx, user)
+	if err != nil {
+		return err
+	}
+
+	err = revokeAllAccess(ctx, qtx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	cache.TryInvalidate(ctx, m.cache, cache.GenerateUserStatusKey(user.ID))
+	return nil
+}
+
+// revokeAllAccess signs the user out everywhere by deleting all of their sessions, tokens and
+// API keys. Any new kind of credential has to be revoked here as well.
+func revokeAllAccess(ctx context.Context, q *sqlc.Queries, userID int64) error {
+	err := q.DeleteAllSessionsForUserExcept(ctx, sqlc.DeleteAllSessionsForUserExceptParams{
+		UserID:   userID,
+		ExceptID: 0,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invitations are tokens of the inviter's but no way into the account, so they are kept.
+	// Whether the inviter can still grant the role is checked when one is accepted.
+	err = q.DeleteAllTokensForUser(ctx, sqlc.DeleteAllTokensForUserParams{
+		UserID:      userID,
+		ExceptScope: ScopeInvitation,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.DeleteAllAPIKeysForUser(ctx, userID)
+}
+
+// DeletePendingDeletion permanently deletes the accounts whose deletion was requested more
+// than gracePeriod ago, and returns how many were deleted.
+func (m UserModel) DeletePendingDeletion(ctx context.Context, gracePeriod time.Duration) (int64, error) {
+	result, err := m.queries.DeleteUsersPendingDeletion(ctx, pgtype.Timestamptz{Time: time.Now().Add(-gracePeriod), Valid: true})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
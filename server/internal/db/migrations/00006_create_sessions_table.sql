@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS sessions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    device_name text NOT NULL,
+    user_agent text NOT NULL,
+    ip text NOT NULL,
+    last_seen_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions(user_id);
+
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS session_id bigint REFERENCES sessions ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS tokens_session_id_idx ON tokens(session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS tokens_session_id_idx;
+ALTER TABLE tokens DROP COLUMN IF EXISTS session_id;
+
+DROP INDEX IF EXISTS sessions_user_id_idx;
+DROP TABLE IF EXISTS sessions;
+-- +goose StatementEnd
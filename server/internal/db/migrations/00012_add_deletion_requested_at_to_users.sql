@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_requested_at timestamp(0) with time zone;
+CREATE INDEX IF NOT EXISTS users_deletion_requested_at_idx ON users(deletion_requested_at) WHERE deletion_requested_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS users_deletion_requested_at_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_requested_at;
+-- +goose StatementEnd
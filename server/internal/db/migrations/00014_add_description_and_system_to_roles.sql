@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS description text NOT NULL DEFAULT '';
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS system bool NOT NULL DEFAULT false;
+UPDATE roles SET system = true WHERE code IN ('superadmin', 'admin', 'moderator', 'user');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE roles DROP COLUMN IF EXISTS system;
+ALTER TABLE roles DROP COLUMN IF EXISTS description;
+-- +goose StatementEnd
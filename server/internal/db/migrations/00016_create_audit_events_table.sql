@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Audit events outlive the users they mention, so actor and target are not foreign keys.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id bigserial PRIMARY KEY,
+    actor_id bigint,
+    target_id bigint,
+    action text NOT NULL,
+    ip text NOT NULL DEFAULT '',
+    user_agent text NOT NULL DEFAULT '',
+    metadata jsonb NOT NULL DEFAULT '{}',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_created_at_idx ON audit_events(created_at);
+CREATE INDEX IF NOT EXISTS audit_events_actor_id_idx ON audit_events(actor_id);
+CREATE INDEX IF NOT EXISTS audit_events_target_id_idx ON audit_events(target_id);
+CREATE INDEX IF NOT EXISTS audit_events_action_idx ON audit_events(action);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+
+DROP INDEX IF EXISTS audit_events_action_idx;
+DROP INDEX IF EXISTS audit_events_target_id_idx;
+DROP INDEX IF EXISTS audit_events_actor_id_idx;
+DROP INDEX IF EXISTS audit_events_created_at_idx;
+
+DROP TABLE IF EXISTS audit_events;
+-- +goose StatementEnd
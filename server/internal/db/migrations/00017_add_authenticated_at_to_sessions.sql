@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Existing sessions were authenticated when they were created, as far as anyone knows.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS authenticated_at timestamp(0) with time zone;
+UPDATE sessions SET authenticated_at = created_at WHERE authenticated_at IS NULL;
+ALTER TABLE sessions ALTER COLUMN authenticated_at SET DEFAULT NOW();
+ALTER TABLE sessions ALTER COLUMN authenticated_at SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sessions DROP COLUMN IF EXISTS authenticated_at;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- name: GetUserForAPIKey :one
+SELECT sqlc.embed(users), sqlc.embed(api_keys)
+FROM users
+INNER JOIN api_keys ON users.id = api_keys.user_id
+WHERE api_keys.prefix = $1
+AND (api_keys.expires_at IS NULL OR api_keys.expires_at > NOW())
+AND users.deletion_requested_at IS NULL
+AND NOT users.disabled;
+
+-- name: InsertAPIKey :one
+INSERT INTO api_keys (user_id, name, prefix, hash, permissions, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetAllAPIKeysForUser :many
+SELECT *
+FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = NOW()
+WHERE id = $1
+AND (last_used_at IS NULL OR last_used_at < sqlc.arg(last_used_before));
+
+-- name: DeleteAPIKey :execresult
+DELETE FROM api_keys
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteAllAPIKeysForUser :exec
+DELETE FROM api_keys
+WHERE user_id = $1;
\ No newline at end of file
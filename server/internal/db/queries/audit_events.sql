@@ -0,0 +1,25 @@
+-- name: InsertAuditEvent :exec
+INSERT INTO audit_events (actor_id, target_id, action, ip, user_agent, metadata)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetAllAuditEvents :many
+SELECT COUNT(*) OVER() AS total, id, actor_id, target_id, action, ip, user_agent, metadata, created_at
+FROM audit_events
+WHERE (sqlc.narg(actor_id)::bigint IS NULL OR actor_id = sqlc.narg(actor_id))
+AND (sqlc.narg(target_id)::bigint IS NULL OR target_id = sqlc.narg(target_id))
+AND (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action))
+AND (sqlc.narg(since)::timestamptz IS NULL OR created_at >= sqlc.narg(since))
+AND (sqlc.narg(until)::timestamptz IS NULL OR created_at < sqlc.narg(until))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(row_limit) OFFSET sqlc.arg(row_offset);
+
+-- name: GetAllAuditEventsForUser :many
+SELECT id, actor_id, target_id, action, ip, user_agent, metadata, created_at
+FROM audit_events
+WHERE actor_id = $1 OR target_id = $1
+ORDER BY created_at DESC, id DESC;
+
+-- name: GetLoginHistory :one
+SELECT
+  EXISTS (SELECT 1 FROM audit_events WHERE target_id = sqlc.arg(user_id) AND action = 'login') AS has_logins,
+  EXISTS (SELECT 1 FROM audit_events WHERE target_id = sqlc.arg(user_id) AND action = 'login' AND user_agent = sqlc.arg(user_agent)) AS known_user_agent;
\ No newline at end of file
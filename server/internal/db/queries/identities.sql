@@ -0,0 +1,17 @@
+-- name: GetUserForIdentity :one
+SELECT users.*
+FROM users
+INNER JOIN user_identities ON users.id = user_identities.user_id
+WHERE user_identities.provider = $1
+AND user_identities.subject = $2;
+
+-- name: InsertUserIdentity :one
+INSERT INTO user_identities (user_id, provider, subject, email)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetAllIdentitiesForUser :many
+SELECT *
+FROM user_identities
+WHERE user_id = $1
+ORDER BY id;
\ No newline at end of file
@@ -0,0 +1,30 @@
+-- name: InsertInvitation :one
+INSERT INTO invitations (token_hash, organization_id, role_id)
+SELECT $1, $2, roles.id FROM roles WHERE roles.code = sqlc.arg(role_code)
+RETURNING *;
+
+-- name: GetInvitationForToken :one
+SELECT sqlc.embed(invitations), sqlc.embed(tokens), roles.code AS role, organizations.name AS organization_name
+FROM invitations
+INNER JOIN tokens ON tokens.hash = invitations.token_hash
+INNER JOIN roles ON roles.id = invitations.role_id
+INNER JOIN organizations ON organizations.id = invitations.organization_id
+WHERE tokens.hash = $1
+AND tokens.scope = $2
+AND tokens.expiry > sqlc.arg(expiry_greater_than);
+
+-- name: GetAllInvitationsForOrganization :many
+SELECT sqlc.embed(invitations), sqlc.embed(tokens), roles.code AS role
+FROM invitations
+INNER JOIN tokens ON tokens.hash = invitations.token_hash
+INNER JOIN roles ON roles.id = invitations.role_id
+WHERE invitations.organization_id = $1
+AND tokens.expiry > sqlc.arg(expiry_greater_than)
+ORDER BY invitations.created_at DESC, invitations.id DESC;
+
+-- name: DeleteInvitation :execresult
+DELETE FROM tokens
+WHERE tokens.hash = (
+    SELECT invitations.token_hash FROM invitations
+    WHERE invitations.id = $1 AND invitations.organization_id = $2
+);
\ No newline at end of file
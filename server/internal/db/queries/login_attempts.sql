@@ -0,0 +1,30 @@
+-- name: GetLoginAttempts :one
+SELECT *
+FROM login_attempts
+WHERE email = $1;
+
+-- name: RecordFailedLogin :one
+INSERT INTO login_attempts (email, failed_count, last_failed_at)
+VALUES (sqlc.arg(email), 1, NOW())
+ON CONFLICT (email) DO UPDATE
+SET failed_count = CASE
+    WHEN login_attempts.last_failed_at < sqlc.arg(reset_before) THEN 1
+    ELSE login_attempts.failed_count + 1
+  END,
+  last_failed_at = NOW(),
+  updated_at = NOW()
+RETURNING *;
+
+-- name: LockLogin :exec
+UPDATE login_attempts
+SET locked_until = $2, updated_at = NOW()
+WHERE email = $1;
+
+-- name: DeleteLoginAttempts :exec
+DELETE FROM login_attempts
+WHERE email = $1;
+
+-- name: DeleteStaleLoginAttempts :exec
+DELETE FROM login_attempts
+WHERE last_failed_at < sqlc.arg(last_failed_before)
+AND (locked_until IS NULL OR locked_until < NOW());
\ No newline at end of file
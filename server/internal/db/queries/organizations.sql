@@ -0,0 +1,65 @@
+-- name: InsertOrganization :one
+INSERT INTO organizations (name, slug)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: GetOrganizationByID :one
+SELECT *
+FROM organizations
+WHERE id = $1;
+
+-- name: GetAllOrganizationsForUser :many
+SELECT sqlc.embed(organizations), roles.code AS role
+FROM organizations
+INNER JOIN organization_members ON organization_members.organization_id = organizations.id
+INNER JOIN roles ON roles.id = organization_members.role_id
+WHERE organization_members.user_id = $1
+ORDER BY organizations.name, organizations.id;
+
+-- name: UpdateOrganization :one
+UPDATE organizations
+SET name = $1, slug = $2, version = version + 1, updated_at = NOW()
+WHERE id = $3 AND version = $4
+RETURNING version;
+
+-- name: DeleteOrganization :execresult
+DELETE FROM organizations
+WHERE id = $1;
+
+-- name: InsertOrganizationMember :execresult
+INSERT INTO organization_members (organization_id, user_id, role_id)
+SELECT $1, $2, roles.id FROM roles WHERE roles.code = sqlc.arg(role_code);
+
+-- name: GetOrganizationMembership :one
+SELECT organization_members.organization_id, organization_members.user_id, roles.code AS role, organization_members.created_at,
+  COALESCE(array_agg(permissions.code) FILTER (WHERE permissions.code IS NOT NULL), '{}')::text[] AS permissions
+FROM organization_members
+INNER JOIN roles ON roles.id = organization_members.role_id
+LEFT JOIN role_permissions ON role_permissions.role_id = roles.id
+LEFT JOIN permissions ON permissions.id = role_permissions.permission_id
+WHERE organization_members.organization_id = $1 AND organization_members.user_id = $2
+GROUP BY organization_members.organization_id, organization_members.user_id, roles.code, organization_members.created_at;
+
+-- name: GetAllOrganizationMembers :many
+SELECT sqlc.embed(users), roles.code AS role, organization_members.created_at AS joined_at
+FROM organization_members
+INNER JOIN users ON users.id = organization_members.user_id
+INNER JOIN roles ON roles.id = organization_members.role_id
+WHERE organization_members.organization_id = $1
+ORDER BY users.full_name, users.id;
+
+-- name: UpdateOrganizationMemberRole :execresult
+UPDATE organization_members
+SET role_id = roles.id, updated_at = NOW()
+FROM roles
+WHERE organization_members.organization_id = $1 AND organization_members.user_id = $2 AND roles.code = sqlc.arg(role_code);
+
+-- name: DeleteOrganizationMember :execresult
+DELETE FROM organization_members
+WHERE organization_id = $1 AND user_id = $2;
+
+-- name: CountOrganizationMembersWithRole :one
+SELECT COUNT(*)
+FROM organization_members
+INNER JOIN roles ON roles.id = organization_members.role_id
+WHERE organization_members.organization_id = $1 AND roles.code = sqlc.arg(role_code);
\ No newline at end of file
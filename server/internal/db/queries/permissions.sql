@@ -16,6 +16,10 @@ INSERT INTO permissions (code)
 SELECT UNNEST($1::text[])
 ON CONFLICT DO NOTHING;
 
+-- name: DeleteAllPermissionsExcept :execresult
+DELETE FROM permissions
+WHERE code <> ALL(sqlc.arg(codes)::text[]);
+
 -- name: AddPermissionsForRole :exec
 INSERT INTO role_permissions (role_id, permission_id)
 SELECT roles.id, permissions.id
@@ -29,4 +33,17 @@ WHERE (role_id, permission_id) IN (
   SELECT roles.id, permissions.id
   FROM roles, permissions
   WHERE roles.code = sqlc.arg(role_code) AND permissions.code = ANY(sqlc.arg(perm_codes)::text[])
-);
\ No newline at end of file
+);
+
+-- name: GetAllPermissions :many
+SELECT code
+FROM permissions
+ORDER BY code;
+
+-- name: GetAllPermissionsForRoleCode :many
+SELECT p.code
+FROM permissions p
+INNER JOIN role_permissions rp ON rp.permission_id = p.id
+INNER JOIN roles r ON r.id = rp.role_id
+WHERE r.code = $1
+ORDER BY p.code;
\ No newline at end of file
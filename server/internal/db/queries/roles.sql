@@ -20,6 +20,34 @@ WHERE user_id = $1 AND role_id = ANY(
 );
 
 -- name: CreateRoles :exec
-INSERT INTO roles (code)
-SELECT UNNEST(sqlc.arg(role_codes)::text[])
-ON CONFLICT DO NOTHING;
\ No newline at end of file
+INSERT INTO roles (code, system)
+SELECT UNNEST(sqlc.arg(role_codes)::text[]), true
+ON CONFLICT (code) DO UPDATE SET system = true;
+
+-- name: GetAllRolesWithPermissions :many
+SELECT r.code, r.description, r.system, r.created_at, r.updated_at, COALESCE(array_agg(p.code ORDER BY p.code) FILTER (WHERE p.code IS NOT NULL), '{}')::text[] AS permissions
+FROM roles r
+LEFT JOIN role_permissions rp ON rp.role_id = r.id
+LEFT JOIN permissions p ON p.id = rp.permission_id
+GROUP BY r.id
+ORDER BY r.code;
+
+-- name: GetRoleByCode :one
+SELECT *
+FROM roles
+WHERE code = $1;
+
+-- name: InsertRole :one
+INSERT INTO roles (code, description)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: UpdateRole :one
+UPDATE roles
+SET code = sqlc.arg(new_code), description = sqlc.arg(description), updated_at = NOW()
+WHERE code = sqlc.arg(code)
+RETURNING *;
+
+-- name: DeleteRole :execresult
+DELETE FROM roles
+WHERE code = $1 AND NOT system;
\ No newline at end of file
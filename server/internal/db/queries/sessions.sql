@@ -4,4 +4,46 @@ FROM users
 INNER JOIN tokens ON users.id = tokens.user_id
 WHERE tokens.hash = $1
 AND tokens.scope = $2
-AND tokens.expiry > sqlc.arg(expiry_greater_than);
\ No newline at end of file
+AND tokens.expiry > sqlc.arg(expiry_greater_than);
+
+-- name: InsertSession :one
+INSERT INTO sessions (user_id, device_name, user_agent, ip, authenticated_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetAllSessionsForUser :many
+SELECT *
+FROM sessions
+WHERE user_id = $1
+ORDER BY last_seen_at DESC, id DESC;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET user_agent = $2, ip = $3, last_seen_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteSession :execresult
+DELETE FROM sessions
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteOrphanedSessions :exec
+DELETE FROM sessions
+WHERE created_at < sqlc.arg(created_before)
+AND NOT EXISTS (
+  SELECT 1 FROM tokens WHERE tokens.session_id = sessions.id
+);
+
+-- name: DeleteAllSessionsForUserExcept :exec
+DELETE FROM sessions
+WHERE user_id = $1 AND id != sqlc.arg(except_id);
+
+-- name: GetSessionAuthenticatedAt :one
+SELECT authenticated_at
+FROM sessions
+WHERE id = $1 AND user_id = $2;
+
+-- name: ReauthenticateSession :one
+UPDATE sessions
+SET authenticated_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING authenticated_at;
\ No newline at end of file
@@ -1,12 +1,28 @@
 -- name: InsertToken :one
-INSERT INTO tokens (hash, user_id, expiry, scope)
-VALUES ($1, $2, $3, $4)
+INSERT INTO tokens (hash, user_id, expiry, scope, family, session_id, email)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 RETURNING *;
 
+-- name: GetTokenByHash :one
+SELECT *
+FROM tokens
+WHERE hash = $1
+AND scope = $2
+AND expiry > sqlc.arg(expiry_greater_than);
+
+-- name: MarkTokenRotated :execresult
+UPDATE tokens
+SET rotated_at = NOW(), updated_at = NOW()
+WHERE hash = $1 AND rotated_at IS NULL;
+
 -- name: DeleteAllForUser :exec
 DELETE FROM tokens
 WHERE scope = $1 AND user_id = $2;
 
+-- name: DeleteAllTokensForUser :exec
+DELETE FROM tokens
+WHERE user_id = $1 AND scope != sqlc.arg(except_scope);
+
 -- name: DeleteAllForUserExceptHash :exec
 DELETE FROM tokens
 WHERE scope = $1 AND user_id = $2 AND hash != $3;
@@ -15,6 +31,16 @@ WHERE scope = $1 AND user_id = $2 AND hash != $3;
 DELETE FROM tokens
 WHERE hash = $1;
 
+-- name: DeleteFamily :exec
+DELETE FROM tokens
+WHERE family = $1;
+
 -- name: DeleteExpiredTokens :exec
 DELETE FROM tokens
-WHERE expiry < NOW();
\ No newline at end of file
+WHERE expiry < NOW();
+
+-- name: DeleteSessionlessTokensForUser :exec
+DELETE FROM tokens
+WHERE user_id = $1
+AND session_id IS NULL
+AND scope = ANY(sqlc.arg(scopes)::text[]);
\ No newline at end of file
@@ -0,0 +1,41 @@
+-- name: GetTOTPForUser :one
+SELECT *
+FROM user_totp
+WHERE user_id = $1;
+
+-- name: UpsertTOTPSecret :exec
+INSERT INTO user_totp (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, enabled_at = NULL, last_used_step = 0, updated_at = NOW();
+
+-- name: EnableTOTP :exec
+UPDATE user_totp
+SET enabled_at = NOW(), updated_at = NOW()
+WHERE user_id = $1;
+
+-- name: UseTOTPStep :execresult
+UPDATE user_totp
+SET last_used_step = sqlc.arg(step), updated_at = NOW()
+WHERE user_id = sqlc.arg(user_id) AND last_used_step < sqlc.arg(step);
+
+-- name: DeleteTOTPForUser :exec
+DELETE FROM user_totp
+WHERE user_id = $1;
+
+-- name: InsertRecoveryCodes :exec
+INSERT INTO user_recovery_codes (hash, user_id)
+SELECT UNNEST(sqlc.arg(hashes)::bytea[]), sqlc.arg(user_id)::bigint;
+
+-- name: GetAllRecoveryCodeHashesForUser :many
+SELECT hash
+FROM user_recovery_codes
+WHERE user_id = $1;
+
+-- name: DeleteRecoveryCode :execresult
+DELETE FROM user_recovery_codes
+WHERE user_id = $1 AND hash = $2;
+
+-- name: DeleteAllRecoveryCodesForUser :exec
+DELETE FROM user_recovery_codes
+WHERE user_id = $1;
\ No newline at end of file
@@ -26,6 +26,10 @@ AND tokens.expiry > sqlc.arg(expiry_greater_than);
 -- name: Update :one
 UPDATE users 
 SET full_name = $1, email = $2, profile_image_url = $3, password_hash = $4, activated = $5, updated_at = $6,
-last_login_at = $7, version = version + 1
-WHERE id = $8 AND version = $9
-RETURNING version;
\ No newline at end of file
+last_login_at = $7, deletion_requested_at = $8, disabled = $9, version = version + 1
+WHERE id = $10 AND version = $11
+RETURNING version;
+
+-- name: DeleteUsersPendingDeletion :execresult
+DELETE FROM users
+WHERE deletion_requested_at < sqlc.arg(requested_before);
\ No newline at end of file
@@ -8,20 +8,81 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-type Permission struct {
+type ApiKey struct {
+	ID          int64
+	UserID      int64
+	Name        string
+	Prefix      string
+	Hash        []byte
+	Permissions []string
+	ExpiresAt   pgtype.Timestamptz
+	LastUsedAt  pgtype.Timestamptz
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+}
+
+type AuditEvent struct {
 	ID        int64
-	Code      string
+	ActorID   pgtype.Int8
+	TargetID  pgtype.Int8
+	Action    string
+	Ip        string
+	UserAgent string
+	Metadata  []byte
+	CreatedAt pgtype.Timestamptz
+}
+
+type Invitation struct {
+	ID             int64
+	TokenHash      []byte
+	OrganizationID int64
+	RoleID         int64
+	CreatedAt      pgtype.Timestamptz
+	UpdatedAt      pgtype.Timestamptz
+}
+
+type LoginAttempt struct {
+	Email        string
+	FailedCount  int32
+	LastFailedAt pgtype.Timestamptz
+	LockedUntil  pgtype.Timestamptz
+	CreatedAt    pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+}
+
+type Organization struct {
+	ID        int64
+	Name      string
+	Slug      string
+	Version   int32
 	CreatedAt pgtype.Timestamptz
 	UpdatedAt pgtype.Timestamptz
 }
 
-type Role struct {
+type OrganizationMember struct {
+	OrganizationID int64
+	UserID         int64
+	RoleID         int64
+	CreatedAt      pgtype.Timestamptz
+	UpdatedAt      pgtype.Timestamptz
+}
+
+type Permission struct {
 	ID        int64
 	Code      string
 	CreatedAt pgtype.Timestamptz
 	UpdatedAt pgtype.Timestamptz
 }
 
+type Role struct {
+	ID          int64
+	Code        string
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+	Description string
+	System      bool
+}
+
 type RolePermission struct {
 	RoleID       int64
 	PermissionID int64
@@ -29,6 +90,18 @@ type RolePermission struct {
 	UpdatedAt    pgtype.Timestamptz
 }
 
+type Session struct {
+	ID              int64
+	UserID          int64
+	DeviceName      string
+	UserAgent       string
+	Ip              string
+	LastSeenAt      pgtype.Timestamptz
+	CreatedAt       pgtype.Timestamptz
+	UpdatedAt       pgtype.Timestamptz
+	AuthenticatedAt pgtype.Timestamptz
+}
+
 type Token struct {
 	Hash      []byte
 	UserID    int64
@@ -36,19 +109,42 @@ type Token struct {
 	Scope     string
 	CreatedAt pgtype.Timestamptz
 	UpdatedAt pgtype.Timestamptz
+	Family    []byte
+	RotatedAt pgtype.Timestamptz
+	SessionID pgtype.Int8
+	Email     pgtype.Text
 }
 
 type User struct {
-	ID              int64
-	FullName        string
-	Email           string
-	PasswordHash    []byte
-	ProfileImageUrl pgtype.Text
-	Activated       bool
-	LastLoginAt     pgtype.Timestamptz
-	Version         int32
-	CreatedAt       pgtype.Timestamptz
-	UpdatedAt       pgtype.Timestamptz
+	ID                  int64
+	FullName            string
+	Email               string
+	PasswordHash        []byte
+	ProfileImageUrl     pgtype.Text
+	Activated           bool
+	LastLoginAt         pgtype.Timestamptz
+	Version             int32
+	CreatedAt           pgtype.Timestamptz
+	UpdatedAt           pgtype.Timestamptz
+	DeletionRequestedAt pgtype.Timestamptz
+	Disabled            bool
+}
+
+type UserIdentity struct {
+	ID        int64
+	UserID    int64
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
+}
+
+type UserRecoveryCode struct {
+	UserID    int64
+	Hash      []byte
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
 }
 
 type UserRole struct {
@@ -57,3 +153,12 @@ type UserRole struct {
 	CreatedAt pgtype.Timestamptz
 	UpdatedAt pgtype.Timestamptz
 }
+
+type UserTotp struct {
+	UserID       int64
+	Secret       []byte
+	EnabledAt    pgtype.Timestamptz
+	LastUsedStep int64
+	CreatedAt    pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+}
@@ -40,6 +40,41 @@ func (q *Queries) Create(ctx context.Context, dollar_1 []string) error {
 	return err
 }
 
+const deleteAllPermissionsExcept = `-- name: DeleteAllPermissionsExcept :execresult
+DELETE FROM permissions
+WHERE code <> ALL($1::text[])
+`
+
+func (q *Queries) DeleteAllPermissionsExcept(ctx context.Context, codes []string) (pgconn.CommandTag, error) {
+	return q.db.Exec(ctx, deleteAllPermissionsExcept, codes)
+}
+
+const getAllPermissions = `-- name: GetAllPermissions :many
+SELECT code
+FROM permissions
+ORDER BY code
+`
+
+func (q *Queries) GetAllPermissions(ctx context.Context) ([]string, error) {
+	rows, err := q.db.Query(ctx, getAllPermissions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		items = append(items, code)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getAllPermissionsForRole = `-- name: GetAllPermissionsForRole :many
 SELECT p.code
 FROM permissions p
@@ -67,6 +102,35 @@ func (q *Queries) GetAllPermissionsForRole(ctx context.Context, roleID int64) ([
 	return items, nil
 }
 
+const getAllPermissionsForRoleCode = `-- name: GetAllPermissionsForRoleCode :many
+SELECT p.code
+FROM permissions p
+INNER JOIN role_permissions rp ON rp.permission_id = p.id
+INNER JOIN roles r ON r.id = rp.role_id
+WHERE r.code = $1
+ORDER BY p.code
+`
+
+func (q *Queries) GetAllPermissionsForRoleCode(ctx context.Context, code string) ([]string, error) {
+	rows, err := q.db.Query(ctx, getAllPermissionsForRoleCode, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		items = append(items, code)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getAllPermissionsForUser = `-- name: GetAllPermissionsForUser :many
 SELECT DISTINCT p.code
 FROM permissions p
@@ -9,6 +9,7 @@ import (
 	"context"
 
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const addRolesForUser = `-- name: AddRolesForUser :exec
@@ -27,9 +28,9 @@ func (q *Queries) AddRolesForUser(ctx context.Context, arg AddRolesForUserParams
 }
 
 const createRoles = `-- name: CreateRoles :exec
-INSERT INTO roles (code)
-SELECT UNNEST($1::text[])
-ON CONFLICT DO NOTHING
+INSERT INTO roles (code, system)
+SELECT UNNEST($1::text[]), true
+ON CONFLICT (code) DO UPDATE SET system = true
 `
 
 func (q *Queries) CreateRoles(ctx context.Context, roleCodes []string) error {
@@ -37,6 +38,15 @@ func (q *Queries) CreateRoles(ctx context.Context, roleCodes []string) error {
 	return err
 }
 
+const deleteRole = `-- name: DeleteRole :execresult
+DELETE FROM roles
+WHERE code = $1 AND NOT system
+`
+
+func (q *Queries) DeleteRole(ctx context.Context, code string) (pgconn.CommandTag, error) {
+	return q.db.Exec(ctx, deleteRole, code)
+}
+
 const getAllRolesForUser = `-- name: GetAllRolesForUser :many
 SELECT DISTINCT r.code
 FROM roles r
@@ -64,6 +74,96 @@ func (q *Queries) GetAllRolesForUser(ctx context.Context, userID int64) ([]strin
 	return items, nil
 }
 
+const getAllRolesWithPermissions = `-- name: GetAllRolesWithPermissions :many
+SELECT r.code, r.description, r.system, r.created_at, r.updated_at, COALESCE(array_agg(p.code ORDER BY p.code) FILTER (WHERE p.code IS NOT NULL), '{}')::text[] AS permissions
+FROM roles r
+LEFT JOIN role_permissions rp ON rp.role_id = r.id
+LEFT JOIN permissions p ON p.id = rp.permission_id
+GROUP BY r.id
+ORDER BY r.code
+`
+
+type GetAllRolesWithPermissionsRow struct {
+	Code        string
+	Description string
+	System      bool
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+	Permissions []string
+}
+
+func (q *Queries) GetAllRolesWithPermissions(ctx context.Context) ([]GetAllRolesWithPermissionsRow, error) {
+	rows, err := q.db.Query(ctx, getAllRolesWithPermissions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAllRolesWithPermissionsRow
+	for rows.Next() {
+		var i GetAllRolesWithPermissionsRow
+		if err := rows.Scan(
+			&i.Code,
+			&i.Description,
+			&i.System,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Permissions,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRoleByCode = `-- name: GetRoleByCode :one
+SELECT id, code, created_at, updated_at, description, system
+FROM roles
+WHERE code = $1
+`
+
+func (q *Queries) GetRoleByCode(ctx context.Context, code string) (Role, error) {
+	row := q.db.QueryRow(ctx, getRoleByCode, code)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.Code,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.System,
+	)
+	return i, err
+}
+
+const insertRole = `-- name: InsertRole :one
+INSERT INTO roles (code, description)
+VALUES ($1, $2)
+RETURNING id, code, created_at, updated_at, description, system
+`
+
+type InsertRoleParams struct {
+	Code        string
+	Description string
+}
+
+func (q *Queries) InsertRole(ctx context.Context, arg InsertRoleParams) (Role, error) {
+	row := q.db.QueryRow(ctx, insertRole, arg.Code, arg.Description)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.Code,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.System,
+	)
+	return i, err
+}
+
 const removeRolesForUser = `-- name: RemoveRolesForUser :execresult
 DELETE FROM user_roles
 WHERE user_id = $1 AND role_id = ANY(
@@ -92,3 +192,30 @@ func (q *Queries) RoleExists(ctx context.Context, code string) (bool, error) {
 	err := row.Scan(&exists)
 	return exists, err
 }
+
+const updateRole = `-- name: UpdateRole :one
+UPDATE roles
+SET code = $1, description = $2, updated_at = NOW()
+WHERE code = $3
+RETURNING id, code, created_at, updated_at, description, system
+`
+
+type UpdateRoleParams struct {
+	NewCode     string
+	Description string
+	Code        string
+}
+
+func (q *Queries) UpdateRole(ctx context.Context, arg UpdateRoleParams) (Role, error) {
+	row := q.db.QueryRow(ctx, updateRole, arg.NewCode, arg.Description, arg.Code)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.Code,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.System,
+	)
+	return i, err
+}
@@ -8,11 +8,109 @@ package sqlc
 import (
 	"context"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const deleteAllSessionsForUserExcept = `-- name: DeleteAllSessionsForUserExcept :exec
+DELETE FROM sessions
+WHERE user_id = $1 AND id != $2
+`
+
+type DeleteAllSessionsForUserExceptParams struct {
+	UserID   int64
+	ExceptID int64
+}
+
+func (q *Queries) DeleteAllSessionsForUserExcept(ctx context.Context, arg DeleteAllSessionsForUserExceptParams) error {
+	_, err := q.db.Exec(ctx, deleteAllSessionsForUserExcept, arg.UserID, arg.ExceptID)
+	return err
+}
+
+const deleteOrphanedSessions = `-- name: DeleteOrphanedSessions :exec
+DELETE FROM sessions
+WHERE created_at < $1
+AND NOT EXISTS (
+  SELECT 1 FROM tokens WHERE tokens.session_id = sessions.id
+)
+`
+
+func (q *Queries) DeleteOrphanedSessions(ctx context.Context, createdBefore pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteOrphanedSessions, createdBefore)
+	return err
+}
+
+const deleteSession = `-- name: DeleteSession :execresult
+DELETE FROM sessions
+WHERE id = $1 AND user_id = $2
+`
+
+type DeleteSessionParams struct {
+	ID     int64
+	UserID int64
+}
+
+func (q *Queries) DeleteSession(ctx context.Context, arg DeleteSessionParams) (pgconn.CommandTag, error) {
+	return q.db.Exec(ctx, deleteSession, arg.ID, arg.UserID)
+}
+
+const getAllSessionsForUser = `-- name: GetAllSessionsForUser :many
+SELECT id, user_id, device_name, user_agent, ip, last_seen_at, created_at, updated_at, authenticated_at
+FROM sessions
+WHERE user_id = $1
+ORDER BY last_seen_at DESC, id DESC
+`
+
+func (q *Queries) GetAllSessionsForUser(ctx context.Context, userID int64) ([]Session, error) {
+	rows, err := q.db.Query(ctx, getAllSessionsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Session
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.DeviceName,
+			&i.UserAgent,
+			&i.Ip,
+			&i.LastSeenAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AuthenticatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSessionAuthenticatedAt = `-- name: GetSessionAuthenticatedAt :one
+SELECT authenticated_at
+FROM sessions
+WHERE id = $1 AND user_id = $2
+`
+
+type GetSessionAuthenticatedAtParams struct {
+	ID     int64
+	UserID int64
+}
+
+func (q *Queries) GetSessionAuthenticatedAt(ctx context.Context, arg GetSessionAuthenticatedAtParams) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, getSessionAuthenticatedAt, arg.ID, arg.UserID)
+	var authenticated_at pgtype.Timestamptz
+	err := row.Scan(&authenticated_at)
+	return authenticated_at, err
+}
+
 const getSessionForToken = `-- name: GetSessionForToken :one
-SELECT users.id, users.full_name, users.email, users.password_hash, users.profile_image_url, users.activated, users.last_login_at, users.version, users.created_at, users.updated_at, tokens.hash, tokens.user_id, tokens.expiry, tokens.scope, tokens.created_at, tokens.updated_at
+SELECT users.id, users.full_name, users.email, users.password_hash, users.profile_image_url, users.activated, users.last_login_at, users.version, users.created_at, users.updated_at, users.deletion_requested_at, users.disabled, tokens.hash, tokens.user_id, tokens.expiry, tokens.scope, tokens.created_at, tokens.updated_at, tokens.family, tokens.rotated_at, tokens.session_id, tokens.email
 FROM users
 INNER JOIN tokens ON users.id = tokens.user_id
 WHERE tokens.hash = $1
@@ -45,12 +143,91 @@ func (q *Queries) GetSessionForToken(ctx context.Context, arg GetSessionForToken
 		&i.User.Version,
 		&i.User.CreatedAt,
 		&i.User.UpdatedAt,
+		&i.User.DeletionRequestedAt,
+		&i.User.Disabled,
 		&i.Token.Hash,
 		&i.Token.UserID,
 		&i.Token.Expiry,
 		&i.Token.Scope,
 		&i.Token.CreatedAt,
 		&i.Token.UpdatedAt,
+		&i.Token.Family,
+		&i.Token.RotatedAt,
+		&i.Token.SessionID,
+		&i.Token.Email,
+	)
+	return i, err
+}
+
+const insertSession = `-- name: InsertSession :one
+INSERT INTO sessions (user_id, device_name, user_agent, ip, authenticated_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, user_id, device_name, user_agent, ip, last_seen_at, created_at, updated_at, authenticated_at
+`
+
+type InsertSessionParams struct {
+	UserID          int64
+	DeviceName      string
+	UserAgent       string
+	Ip              string
+	AuthenticatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) InsertSession(ctx context.Context, arg InsertSessionParams) (Session, error) {
+	row := q.db.QueryRow(ctx, insertSession,
+		arg.UserID,
+		arg.DeviceName,
+		arg.UserAgent,
+		arg.Ip,
+		arg.AuthenticatedAt,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.DeviceName,
+		&i.UserAgent,
+		&i.Ip,
+		&i.LastSeenAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.AuthenticatedAt,
 	)
 	return i, err
 }
+
+const reauthenticateSession = `-- name: ReauthenticateSession :one
+UPDATE sessions
+SET authenticated_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING authenticated_at
+`
+
+type ReauthenticateSessionParams struct {
+	ID     int64
+	UserID int64
+}
+
+func (q *Queries) ReauthenticateSession(ctx context.Context, arg ReauthenticateSessionParams) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, reauthenticateSession, arg.ID, arg.UserID)
+	var authenticated_at pgtype.Timestamptz
+	err := row.Scan(&authenticated_at)
+	return authenticated_at, err
+}
+
+const touchSession = `-- name: TouchSession :exec
+UPDATE sessions
+SET user_agent = $2, ip = $3, last_seen_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+type TouchSessionParams struct {
+	ID        int64
+	UserAgent string
+	Ip        string
+}
+
+func (q *Queries) TouchSession(ctx context.Context, arg TouchSessionParams) error {
+	_, err := q.db.Exec(ctx, touchSession, arg.ID, arg.UserAgent, arg.Ip)
+	return err
+}
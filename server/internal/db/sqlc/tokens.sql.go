@@ -8,6 +8,7 @@ package sqlc
 import (
 	"context"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -42,6 +43,21 @@ func (q *Queries) DeleteAllForUserExceptHash(ctx context.Context, arg DeleteAllF
 	return err
 }
 
+const deleteAllTokensForUser = `-- name: DeleteAllTokensForUser :exec
+DELETE FROM tokens
+WHERE user_id = $1 AND scope != $2
+`
+
+type DeleteAllTokensForUserParams struct {
+	UserID      int64
+	ExceptScope string
+}
+
+func (q *Queries) DeleteAllTokensForUser(ctx context.Context, arg DeleteAllTokensForUserParams) error {
+	_, err := q.db.Exec(ctx, deleteAllTokensForUser, arg.UserID, arg.ExceptScope)
+	return err
+}
+
 const deleteByHash = `-- name: DeleteByHash :exec
 DELETE FROM tokens
 WHERE hash = $1
@@ -62,17 +78,79 @@ func (q *Queries) DeleteExpiredTokens(ctx context.Context) error {
 	return err
 }
 
+const deleteFamily = `-- name: DeleteFamily :exec
+DELETE FROM tokens
+WHERE family = $1
+`
+
+func (q *Queries) DeleteFamily(ctx context.Context, family []byte) error {
+	_, err := q.db.Exec(ctx, deleteFamily, family)
+	return err
+}
+
+const deleteSessionlessTokensForUser = `-- name: DeleteSessionlessTokensForUser :exec
+DELETE FROM tokens
+WHERE user_id = $1
+AND session_id IS NULL
+AND scope = ANY($2::text[])
+`
+
+type DeleteSessionlessTokensForUserParams struct {
+	UserID int64
+	Scopes []string
+}
+
+func (q *Queries) DeleteSessionlessTokensForUser(ctx context.Context, arg DeleteSessionlessTokensForUserParams) error {
+	_, err := q.db.Exec(ctx, deleteSessionlessTokensForUser, arg.UserID, arg.Scopes)
+	return err
+}
+
+const getTokenByHash = `-- name: GetTokenByHash :one
+SELECT hash, user_id, expiry, scope, created_at, updated_at, family, rotated_at, session_id, email
+FROM tokens
+WHERE hash = $1
+AND scope = $2
+AND expiry > $3
+`
+
+type GetTokenByHashParams struct {
+	Hash              []byte
+	Scope             string
+	ExpiryGreaterThan pgtype.Timestamptz
+}
+
+func (q *Queries) GetTokenByHash(ctx context.Context, arg GetTokenByHashParams) (Token, error) {
+	row := q.db.QueryRow(ctx, getTokenByHash, arg.Hash, arg.Scope, arg.ExpiryGreaterThan)
+	var i Token
+	err := row.Scan(
+		&i.Hash,
+		&i.UserID,
+		&i.Expiry,
+		&i.Scope,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Family,
+		&i.RotatedAt,
+		&i.SessionID,
+		&i.Email,
+	)
+	return i, err
+}
+
 const insertToken = `-- name: InsertToken :one
-INSERT INTO tokens (hash, user_id, expiry, scope)
-VALUES ($1, $2, $3, $4)
-RETURNING hash, user_id, expiry, scope, created_at, updated_at
+INSERT INTO tokens (hash, user_id, expiry, scope, family, session_id, email)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING hash, user_id, expiry, scope, created_at, updated_at, family, rotated_at, session_id, email
 `
 
 type InsertTokenParams struct {
-	Hash   []byte
-	UserID int64
-	Expiry pgtype.Timestamptz
-	Scope  string
+	Hash      []byte
+	UserID    int64
+	Expiry    pgtype.Timestamptz
+	Scope     string
+	Family    []byte
+	SessionID pgtype.Int8
+	Email     pgtype.Text
 }
 
 func (q *Queries) InsertToken(ctx context.Context, arg InsertTokenParams) (Token, error) {
@@ -81,6 +159,9 @@ func (q *Queries) InsertToken(ctx context.Context, arg InsertTokenParams) (Token
 		arg.UserID,
 		arg.Expiry,
 		arg.Scope,
+		arg.Family,
+		arg.SessionID,
+		arg.Email,
 	)
 	var i Token
 	err := row.Scan(
@@ -90,6 +171,20 @@ func (q *Queries) InsertToken(ctx context.Context, arg InsertTokenParams) (Token
 		&i.Scope,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Family,
+		&i.RotatedAt,
+		&i.SessionID,
+		&i.Email,
 	)
 	return i, err
 }
+
+const markTokenRotated = `-- name: MarkTokenRotated :execresult
+UPDATE tokens
+SET rotated_at = NOW(), updated_at = NOW()
+WHERE hash = $1 AND rotated_at IS NULL
+`
+
+func (q *Queries) MarkTokenRotated(ctx context.Context, hash []byte) (pgconn.CommandTag, error) {
+	return q.db.Exec(ctx, markTokenRotated, hash)
+}
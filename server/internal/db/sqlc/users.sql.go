@@ -8,11 +8,21 @@ package sqlc
 import (
 	"context"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const deleteUsersPendingDeletion = `-- name: DeleteUsersPendingDeletion :execresult
+DELETE FROM users
+WHERE deletion_requested_at < $1
+`
+
+func (q *Queries) DeleteUsersPendingDeletion(ctx context.Context, requestedBefore pgtype.Timestamptz) (pgconn.CommandTag, error) {
+	return q.db.Exec(ctx, deleteUsersPendingDeletion, requestedBefore)
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, full_name, email, password_hash, profile_image_url, activated, last_login_at, version, created_at, updated_at
+SELECT id, full_name, email, password_hash, profile_image_url, activated, last_login_at, version, created_at, updated_at, deletion_requested_at, disabled
 FROM users
 WHERE email = $1
 LIMIT 1
@@ -32,12 +42,14 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Version,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletionRequestedAt,
+		&i.Disabled,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, full_name, email, password_hash, profile_image_url, activated, last_login_at, version, created_at, updated_at
+SELECT id, full_name, email, password_hash, profile_image_url, activated, last_login_at, version, created_at, updated_at, deletion_requested_at, disabled
 FROM users
 WHERE id = $1
 LIMIT 1
@@ -57,12 +69,14 @@ func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
 		&i.Version,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletionRequestedAt,
+		&i.Disabled,
 	)
 	return i, err
 }
 
 const getUserForToken = `-- name: GetUserForToken :one
-SELECT users.id, users.full_name, users.email, users.password_hash, users.profile_image_url, users.activated, users.last_login_at, users.version, users.created_at, users.updated_at
+SELECT users.id, users.full_name, users.email, users.password_hash, users.profile_image_url, users.activated, users.last_login_at, users.version, users.created_at, users.updated_at, users.deletion_requested_at, users.disabled
 FROM users
 INNER JOIN tokens ON users.id = tokens.user_id
 WHERE tokens.hash = $1
@@ -90,6 +104,8 @@ func (q *Queries) GetUserForToken(ctx context.Context, arg GetUserForTokenParams
 		&i.Version,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletionRequestedAt,
+		&i.Disabled,
 	)
 	return i, err
 }
@@ -97,7 +113,7 @@ func (q *Queries) GetUserForToken(ctx context.Context, arg GetUserForTokenParams
 const insertUser = `-- name: InsertUser :one
 INSERT INTO users (full_name, email, profile_image_url, password_hash, activated)
 VALUES ($1, $2, $3, $4, $5)
-RETURNING id, full_name, email, password_hash, profile_image_url, activated, last_login_at, version, created_at, updated_at
+RETURNING id, full_name, email, password_hash, profile_image_url, activated, last_login_at, version, created_at, updated_at, deletion_requested_at, disabled
 `
 
 type InsertUserParams struct {
@@ -128,6 +144,8 @@ func (q *Queries) InsertUser(ctx context.Context, arg InsertUserParams) (User, e
 		&i.Version,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DeletionRequestedAt,
+		&i.Disabled,
 	)
 	return i, err
 }
@@ -135,21 +153,23 @@ func (q *Queries) InsertUser(ctx context.Context, arg InsertUserParams) (User, e
 const update = `-- name: Update :one
 UPDATE users 
 SET full_name = $1, email = $2, profile_image_url = $3, password_hash = $4, activated = $5, updated_at = $6,
-last_login_at = $7, version = version + 1
-WHERE id = $8 AND version = $9
+last_login_at = $7, deletion_requested_at = $8, disabled = $9, version = version + 1
+WHERE id = $10 AND version = $11
 RETURNING version
 `
 
 type UpdateParams struct {
-	FullName        string
-	Email           string
-	ProfileImageUrl pgtype.Text
-	PasswordHash    []byte
-	Activated       bool
-	UpdatedAt       pgtype.Timestamptz
-	LastLoginAt     pgtype.Timestamptz
-	ID              int64
-	Version         int32
+	FullName            string
+	Email               string
+	ProfileImageUrl     pgtype.Text
+	PasswordHash        []byte
+	Activated           bool
+	UpdatedAt           pgtype.Timestamptz
+	LastLoginAt         pgtype.Timestamptz
+	DeletionRequestedAt pgtype.Timestamptz
+	Disabled            bool
+	ID                  int64
+	Version             int32
 }
 
 func (q *Queries) Update(ctx context.Context, arg UpdateParams) (int32, error) {
@@ -161,6 +181,8 @@ func (q *Queries) Update(ctx context.Context, arg UpdateParams) (int32, error) {
 		arg.Activated,
 		arg.UpdatedAt,
 		arg.LastLoginAt,
+		arg.DeletionRequestedAt,
+		arg.Disabled,
 		arg.ID,
 		arg.Version,
 	)
@@ -0,0 +1,226 @@
+// Package hasher hashes passwords with argon2id or bcrypt. Argon2id hashes are encoded in
+// the PHC string format, such as "$argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>"; bcrypt
+// hashes keep the "$2a$<cost>$..." form every bcrypt library reads, which PHC is modelled
+// on. Since each hash records the algorithm and parameters it was made with, raising the
+// parameters or switching algorithms doesn't break existing hashes: they still verify, and
+// are reported as due for a rehash.
+package hasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidHash   = errors.New("invalid password hash")
+	ErrUnknownScheme = errors.New("unknown password hash scheme")
+)
+
+var b64 = base64.RawStdEncoding
+
+// Scheme is a password hashing algorithm together with its parameters.
+type Scheme interface {
+	// Name identifies the algorithm in encoded hashes.
+	Name() string
+	Hash(password []byte) (string, error)
+	// Verify reports whether the password matches the encoded hash, and whether the hash
+	// was made with parameters other than the scheme's.
+	Verify(password []byte, encoded string) (match, outdated bool, err error)
+}
+
+// Argon2id hashes with argon2id. Memory is in KiB.
+type Argon2id struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2id uses the parameters recommended by OWASP.
+var DefaultArgon2id = Argon2id{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func (a Argon2id) Name() string {
+	return "argon2id"
+}
+
+func (a Argon2id) Hash(password []byte) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	rand.Read(salt)
+
+	key := argon2.IDKey(password, salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (a Argon2id) Verify(password []byte, encoded string) (bool, bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != a.Name() {
+		return false, false, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, ErrInvalidHash
+	}
+
+	var params Argon2id
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return false, false, ErrInvalidHash
+	}
+
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrInvalidHash
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false, ErrInvalidHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	other := argon2.IDKey(password, salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	match := subtle.ConstantTimeCompare(key, other) == 1
+
+	return match, params != a, nil
+}
+
+// Bcrypt hashes with bcrypt, which only uses the first 72 bytes of a password and refuses
+// to hash longer ones.
+type Bcrypt struct {
+	Cost int
+}
+
+var DefaultBcrypt = Bcrypt{Cost: 12}
+
+// bcryptMaxPasswordBytes is the longest password bcrypt hashes.
+const bcryptMaxPasswordBytes = 72
+
+func (b Bcrypt) Name() string {
+	return "bcrypt"
+}
+
+func (b Bcrypt) Hash(password []byte) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword(password, b.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (b Bcrypt) Verify(password []byte, encoded string) (bool, bool, error) {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return false, false, ErrInvalidHash
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(encoded), password)
+	if err != nil {
+		switch {
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, cost != b.Cost, nil
+		default:
+			return false, false, err
+		}
+	}
+	return true, cost != b.Cost, nil
+}
+
+// Hasher hashes passwords with its current scheme, and verifies hashes made with any of its
+// schemes.
+type Hasher struct {
+	current   Scheme
+	schemes   map[string]Scheme
+	dummyHash func() string
+}
+
+// New returns a hasher that hashes with the current scheme. Hashes made with the other
+// schemes are still verified, and always reported as due for a rehash.
+func New(current Scheme, others ...Scheme) *Hasher {
+	h := &Hasher{
+		current: current,
+		schemes: map[string]Scheme{current.Name(): current},
+		// Made once, so that DummyVerify costs what verifying a real hash would.
+		dummyHash: sync.OnceValue(func() string {
+			hash, _ := current.Hash([]byte("dummy password"))
+			return hash
+		}),
+	}
+	for _, scheme := range others {
+		if _, ok := h.schemes[scheme.Name()]; !ok {
+			h.schemes[scheme.Name()] = scheme
+		}
+	}
+	return h
+}
+
+// MaxPasswordBytes returns the length of the longest password the current scheme hashes,
+// or 0 if there is no limit.
+func (h *Hasher) MaxPasswordBytes() int {
+	if _, ok := h.current.(Bcrypt); ok {
+		return bcryptMaxPasswordBytes
+	}
+	return 0
+}
+
+func (h *Hasher) Hash(password string) ([]byte, error) {
+	hash, err := h.current.Hash([]byte(password))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(hash), nil
+}
+
+// Verify reports whether the password matches the hash, and whether the hash should be
+// replaced by a new one because it was made with another scheme or other parameters.
+func (h *Hasher) Verify(password string, hash []byte) (match, rehash bool, err error) {
+	scheme, ok := h.schemes[schemeName(string(hash))]
+	if !ok {
+		return false, false, ErrUnknownScheme
+	}
+
+	match, outdated, err := scheme.Verify([]byte(password), string(hash))
+	if err != nil {
+		return false, false, err
+	}
+
+	return match, match && (outdated || scheme.Name() != h.current.Name()), nil
+}
+
+// DummyVerify takes as long as verifying a password does, without a hash to verify it
+// against. It keeps failed logins for unknown users from being faster than others.
+func (h *Hasher) DummyVerify(password string) {
+	h.current.Verify([]byte(password), h.dummyHash())
+}
+
+// schemeName returns the name of the scheme of the encoded hash, taken from its first
+// $-delimited field. The bcrypt versions all map to bcrypt.
+func schemeName(encoded string) string {
+	fields := strings.SplitN(encoded, "$", 3)
+	if len(fields) < 3 || fields[0] != "" {
+		return ""
+	}
+
+	switch fields[1] {
+	case "2a", "2b", "2y":
+		return "bcrypt"
+	default:
+		return fields[1]
+	}
+}
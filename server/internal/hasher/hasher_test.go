@@ -0,0 +1,162 @@
+package hasher_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"adcentra.ai/internal/hasher"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cheap parameters, so the tests run quickly.
+var (
+	testArgon2id = hasher.Argon2id{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	testBcrypt   = hasher.Bcrypt{Cost: bcrypt.MinCost}
+)
+
+func TestHashVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme hasher.Scheme
+		prefix string
+	}{
+		{"argon2id", testArgon2id, "$argon2id$v=19$m=64,t=1,p=1$"},
+		{"bcrypt", testBcrypt, "$2a$04$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := hasher.New(tt.scheme)
+
+			hash, err := h.Hash("correct horse battery staple")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(hash), tt.prefix) {
+				t.Errorf("Hash() = %s, want prefix %s", hash, tt.prefix)
+			}
+
+			match, rehash, err := h.Verify("correct horse battery staple", hash)
+			if err != nil || !match || rehash {
+				t.Errorf("Verify(correct) = %t, %t, %v, want true, false, nil", match, rehash, err)
+			}
+
+			match, rehash, err = h.Verify("wrong horse battery staple", hash)
+			if err != nil || match || rehash {
+				t.Errorf("Verify(wrong) = %t, %t, %v, want false, false, nil", match, rehash, err)
+			}
+
+			other, err := h.Hash("correct horse battery staple")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(other) == string(hash) {
+				t.Error("Hash() returned the same hash twice, want a new salt each time")
+			}
+		})
+	}
+}
+
+func TestVerifyRehash(t *testing.T) {
+	const password = "correct horse battery staple"
+
+	stronger := testArgon2id
+	stronger.Iterations++
+
+	tests := []struct {
+		name   string
+		old    *hasher.Hasher
+		new    *hasher.Hasher
+		rehash bool
+	}{
+		{"same argon2id parameters", hasher.New(testArgon2id), hasher.New(testArgon2id), false},
+		{"changed argon2id parameters", hasher.New(testArgon2id), hasher.New(stronger), true},
+		{"changed bcrypt cost", hasher.New(testBcrypt), hasher.New(hasher.Bcrypt{Cost: bcrypt.MinCost + 1}), true},
+		{"bcrypt to argon2id", hasher.New(testBcrypt), hasher.New(testArgon2id, testBcrypt), true},
+		{"argon2id to bcrypt", hasher.New(testArgon2id), hasher.New(testBcrypt, testArgon2id), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.old.Hash(password)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			match, rehash, err := tt.new.Verify(password, hash)
+			if err != nil || !match || rehash != tt.rehash {
+				t.Errorf("Verify(correct) = %t, %t, %v, want true, %t, nil", match, rehash, err, tt.rehash)
+			}
+
+			// A failed verification says nothing about the hash worth acting on.
+			match, rehash, err = tt.new.Verify("wrong", hash)
+			if err != nil || match || rehash {
+				t.Errorf("Verify(wrong) = %t, %t, %v, want false, false, nil", match, rehash, err)
+			}
+		})
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	h := hasher.New(testArgon2id, testBcrypt)
+
+	tests := []struct {
+		name string
+		hash string
+		err  error
+	}{
+		{"empty", "", hasher.ErrUnknownScheme},
+		{"no scheme", "plaintext", hasher.ErrUnknownScheme},
+		{"unknown scheme", "$scrypt$ln=15,r=8,p=1$c2FsdA$a2V5", hasher.ErrUnknownScheme},
+		{"scheme not enabled", "$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5", hasher.ErrUnknownScheme},
+		{"missing fields", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA", hasher.ErrInvalidHash},
+		{"wrong version", "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5", hasher.ErrInvalidHash},
+		{"bad parameters", "$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5", hasher.ErrInvalidHash},
+		{"bad salt", "$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5", hasher.ErrInvalidHash},
+		{"bad key", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$!!!", hasher.ErrInvalidHash},
+		{"empty key", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$", hasher.ErrInvalidHash},
+		{"bad bcrypt cost", "$2a$xx$abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ012", hasher.ErrInvalidHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash, err := h.Verify("password", []byte(tt.hash))
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.err)
+			}
+			if match || rehash {
+				t.Errorf("Verify() = %t, %t, want false, false", match, rehash)
+			}
+		})
+	}
+}
+
+func TestMaxPasswordBytes(t *testing.T) {
+	if got := hasher.New(testBcrypt, testArgon2id).MaxPasswordBytes(); got != 72 {
+		t.Errorf("bcrypt MaxPasswordBytes() = %d, want 72", got)
+	}
+	if got := hasher.New(testArgon2id, testBcrypt).MaxPasswordBytes(); got != 0 {
+		t.Errorf("argon2id MaxPasswordBytes() = %d, want 0", got)
+	}
+
+	if _, err := hasher.New(testBcrypt).Hash(strings.Repeat("a", 72)); err != nil {
+		t.Errorf("bcrypt Hash() of 72 bytes error = %v, want nil", err)
+	}
+	if _, err := hasher.New(testBcrypt).Hash(strings.Repeat("a", 73)); err == nil {
+		t.Error("bcrypt Hash() of 73 bytes error = nil, want an error")
+	}
+	if _, err := hasher.New(testArgon2id).Hash(strings.Repeat("a", 1024)); err != nil {
+		t.Errorf("argon2id Hash() of 1024 bytes error = %v, want nil", err)
+	}
+}
+
+func TestDummyVerify(t *testing.T) {
+	for _, scheme := range []hasher.Scheme{testArgon2id, testBcrypt} {
+		t.Run(scheme.Name(), func(t *testing.T) {
+			h := hasher.New(scheme)
+			h.DummyVerify("password")
+			h.DummyVerify(strings.Repeat("a", 100))
+		})
+	}
+}
@@ -3,6 +3,8 @@ package mailer
 import (
 	"bytes"
 	"embed"
+	"io/fs"
+	"strings"
 	"time"
 
 	"github.com/wneessen/go-mail"
@@ -41,7 +43,24 @@ func New(host string, port int, username, password, sender string) (*Mailer, err
 }
 
 func (m *Mailer) Send(recipient string, templateFile string, data any) error {
-	textTmpl, err := tt.New("").ParseFS(templateFS, "templates/"+templateFile)
+	return m.send(recipient, "templates/"+templateFile, data)
+}
+
+// SendLocalized sends the translation of the template in templates/<language>/ when there
+// is one, and the default template otherwise.
+func (m *Mailer) SendLocalized(recipient string, language string, templateFile string, data any) error {
+	base, _, _ := strings.Cut(language, "-")
+
+	path := "templates/" + base + "/" + templateFile
+	if _, err := fs.Stat(templateFS, path); err != nil {
+		path = "templates/" + templateFile
+	}
+
+	return m.send(recipient, path, data)
+}
+
+func (m *Mailer) send(recipient string, path string, data any) error {
+	textTmpl, err := tt.New("").ParseFS(templateFS, path)
 	if err != nil {
 		return err
 	}
@@ -58,7 +77,7 @@ func (m *Mailer) Send(recipient string, templateFile string, data any) error {
 		return err
 	}
 
-	htmlTmpl, err := ht.New("").ParseFS(templateFS, "templates/"+templateFile)
+	htmlTmpl, err := ht.New("").ParseFS(templateFS, path)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,256 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"adcentra.ai/internal/oauth"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/coreos/go-oidc/v3/oidc/oidctest"
+)
+
+const (
+	testClientID = "test-client"
+	testKeyID    = "test-key"
+	testCode     = "test-code"
+)
+
+// stubProvider is a local OpenID Connect provider. Discovery and keys are served by
+// oidctest; the token endpoint redeems testCode, checking the PKCE verifier against the
+// challenge of the last authorization request, for an ID token with the given claims.
+type stubProvider struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+
+	claims      map[string]any
+	signingKey  *rsa.PrivateKey
+	omitIDToken bool
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &stubProvider{key: key}
+
+	discovery := &oidctest.Server{
+		PublicKeys: []oidctest.PublicKey{
+			{PublicKey: key.Public(), KeyID: testKeyID, Algorithm: oidc.RS256},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", discovery)
+	mux.HandleFunc("POST /token", p.serveToken)
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	discovery.SetIssuer(p.server.URL)
+
+	return p
+}
+
+func (p *stubProvider) serveToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if r.PostForm.Get("code") != testCode || base64.RawURLEncoding.EncodeToString(sum[:]) != p.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	response := map[string]any{
+		"access_token": "test-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	}
+
+	if !p.omitIDToken {
+		claims, err := json.Marshal(p.claims)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		signingKey := p.key
+		if p.signingKey != nil {
+			signingKey = p.signingKey
+		}
+		response["id_token"] = oidctest.SignIDToken(signingKey, testKeyID, oidc.RS256, string(claims))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// authorize starts a login against the provider like the start handler does, remembering
+// the PKCE challenge of the authorization request, and returns its nonce.
+func (p *stubProvider) authorize(t *testing.T, provider *oauth.Provider, verifier string) string {
+	t.Helper()
+
+	const nonce = "test-nonce"
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "test-state", nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := u.Query()
+	if got := query.Get("code_challenge_method"); got != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", got)
+	}
+	if got := query.Get("nonce"); got != nonce {
+		t.Fatalf("nonce = %q, want %q", got, nonce)
+	}
+	p.challenge = query.Get("code_challenge")
+
+	return nonce
+}
+
+func TestProviderExchange(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		claims        func(claims map[string]any)
+		signingKey    *rsa.PrivateKey
+		omitIDToken   bool
+		wrongVerifier bool
+		want          *oauth.Identity
+		wantErr       error
+	}{
+		{
+			name: "valid",
+			want: &oauth.Identity{
+				Subject:       "subject-1",
+				Email:         "alice@example.com",
+				EmailVerified: true,
+				Name:          "Alice",
+			},
+		},
+		{
+			name:   "unverified email",
+			claims: func(claims map[string]any) { claims["email_verified"] = false },
+			want: &oauth.Identity{
+				Subject: "subject-1",
+				Email:   "alice@example.com",
+				Name:    "Alice",
+			},
+		},
+		{
+			name:    "nonce mismatch",
+			claims:  func(claims map[string]any) { claims["nonce"] = "other-nonce" },
+			wantErr: oauth.ErrNonceMismatch,
+		},
+		{
+			name:        "missing id token",
+			omitIDToken: true,
+			wantErr:     oauth.ErrMissingIDToken,
+		},
+		{
+			name:          "wrong verifier",
+			wrongVerifier: true,
+		},
+		{
+			name:   "other audience",
+			claims: func(claims map[string]any) { claims["aud"] = "other-client" },
+		},
+		{
+			name:   "other issuer",
+			claims: func(claims map[string]any) { claims["iss"] = "https://issuer.example.com" },
+		},
+		{
+			name:   "expired",
+			claims: func(claims map[string]any) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+		},
+		{
+			name:       "unknown signing key",
+			signingKey: otherKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newStubProvider(t)
+
+			provider := oauth.NewProvider(oauth.Config{
+				Name:        "stub",
+				IssuerURL:   stub.server.URL,
+				ClientID:    testClientID,
+				RedirectURL: "http://localhost/v1/oauth/stub/callback",
+			})
+
+			verifier := oauth.GenerateVerifier()
+			nonce := stub.authorize(t, provider, verifier)
+
+			stub.claims = map[string]any{
+				"iss":            stub.server.URL,
+				"aud":            testClientID,
+				"sub":            "subject-1",
+				"exp":            time.Now().Add(time.Hour).Unix(),
+				"iat":            time.Now().Unix(),
+				"nonce":          nonce,
+				"email":          "alice@example.com",
+				"email_verified": true,
+				"name":           "Alice",
+			}
+			if tt.claims != nil {
+				tt.claims(stub.claims)
+			}
+			stub.signingKey = tt.signingKey
+			stub.omitIDToken = tt.omitIDToken
+
+			if tt.wrongVerifier {
+				verifier = oauth.GenerateVerifier()
+			}
+
+			identity, err := provider.Exchange(context.Background(), testCode, nonce, verifier)
+
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("Exchange() = %+v, want an error", identity)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Exchange() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if *identity != *tt.want {
+				t.Errorf("Exchange() = %+v, want %+v", identity, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Package policy decides whether a subject may perform an action on a resource. Rules
+// combine permission codes, ownership of the resource and organization membership, so that
+// a permission can be granted globally or only for the members of an organization. Policies
+// are plain values and are evaluated without any I/O; callers load the subject and the
+// resource first.
+package policy
+
+import (
+	"slices"
+
+	"adcentra.ai/internal/data"
+)
+
+type Action string
+
+const (
+	// ViewUser and ManageUser cover the user administration endpoints.
+	ViewUser   Action = "user:view"
+	ManageUser Action = "user:manage"
+	// ManageUserAccount covers what affects the account wherever it is used: how the user
+	// signs in and whether they can.
+	ManageUserAccount Action = "user:manage-account"
+	// RevokeUserSessions signs a user out of every device.
+	RevokeUserSessions Action = "user:revoke-sessions"
+)
+
+// Subject is the user performing an action. Permissions are the ones held globally;
+// OrgPermissions are the ones held through the membership of the active organization, if
+// there is one.
+type Subject struct {
+	UserID         int64
+	Roles          data.Roles
+	Permissions    data.Permissions
+	OrganizationID int64
+	OrgPermissions data.Permissions
+}
+
+// Resource describes the thing acted upon. OwnerID is the user it belongs to, which for a
+// user is the user themselves, OwnerRoles and OwnerPermissions what that user holds
+// globally, and OrganizationIDs the organizations it is part of.
+type Resource struct {
+	OwnerID          int64
+	OwnerRoles       data.Roles
+	OwnerPermissions data.Permissions
+	OrganizationIDs  []int64
+}
+
+// Rule reports whether the subject may act on the resource.
+type Rule func(s Subject, r Resource) bool
+
+// Policy maps actions to the rules that allow them. An action is allowed when any of its
+// rules allows it, and denied when it has none. Superadmins are allowed everything.
+type Policy struct {
+	rules map[Action][]Rule
+}
+
+func New() *Policy {
+	return &Policy{rules: make(map[Action][]Rule)}
+}
+
+// Allow adds a rule that allows the action.
+func (p *Policy) Allow(action Action, rule Rule) *Policy {
+	p.rules[action] = append(p.rules[action], rule)
+	return p
+}
+
+func (p *Policy) Can(s Subject, action Action, r Resource) bool {
+	if s.Roles.Has(data.RoleSuperAdmin) {
+		return true
+	}
+
+	for _, rule := range p.rules[action] {
+		if rule(s, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Default returns the policy of the API. A users:view or users:manage permission held
+// through an organization role only reaches the members of that organization, and never
+// users with global roles beyond the default one; it only reaches their account as a whole
+// if they belong to no other organization. Held globally, users:manage only reaches users
+// whose roles the subject could grant, so that it can't be used against superiors.
+func Default() *Policy {
+	orgScoped := func(code data.PermCode) Rule {
+		return All(HasOrgPermission(code), OwnerHasOnlyRoles(data.RoleUser))
+	}
+	global := func(code data.PermCode) Rule {
+		return All(HasPermission(code), CanGrantOwnerRoles())
+	}
+
+	return New().
+		Allow(ViewUser, HasPermission(data.PermUsersView)).
+		Allow(ViewUser, orgScoped(data.PermUsersView)).
+		Allow(ManageUser, global(data.PermUsersManage)).
+		Allow(ManageUser, orgScoped(data.PermUsersManage)).
+		Allow(ManageUserAccount, global(data.PermUsersManage)).
+		Allow(ManageUserAccount, All(orgScoped(data.PermUsersManage), OnlyInOrganization())).
+		Allow(RevokeUserSessions, IsOwner()).
+		Allow(RevokeUserSessions, global(data.PermUsersManage)).
+		Allow(RevokeUserSessions, orgScoped(data.PermUsersManage))
+}
+
+// HasPermission allows subjects holding all the permissions globally.
+func HasPermission(codes ...data.PermCode) Rule {
+	return func(s Subject, r Resource) bool {
+		return s.Permissions.HasAll(codes...)
+	}
+}
+
+// HasOrgPermission allows subjects holding all the permissions in the active organization,
+// provided the resource is part of it.
+func HasOrgPermission(codes ...data.PermCode) Rule {
+	return func(s Subject, r Resource) bool {
+		return InOrganization()(s, r) && s.OrgPermissions.HasAll(codes...)
+	}
+}
+
+// InOrganization allows subjects acting in an organization the resource is part of.
+func InOrganization() Rule {
+	return func(s Subject, r Resource) bool {
+		return s.OrganizationID != 0 && slices.Contains(r.OrganizationIDs, s.OrganizationID)
+	}
+}
+
+// OnlyInOrganization allows subjects acting in the only organization the resource is part
+// of.
+func OnlyInOrganization() Rule {
+	return func(s Subject, r Resource) bool {
+		return s.OrganizationID != 0 && len(r.OrganizationIDs) == 1 && r.OrganizationIDs[0] == s.OrganizationID
+	}
+}
+
+// IsOwner allows the user the resource belongs to.
+func IsOwner() Rule {
+	return func(s Subject, r Resource) bool {
+		return s.UserID != 0 && s.UserID == r.OwnerID
+	}
+}
+
+// OwnerHasOnlyRoles allows acting on resources whose owner holds no global roles other
+// than the given ones.
+func OwnerHasOnlyRoles(roles ...data.Role) Rule {
+	return func(s Subject, r Resource) bool {
+		for _, role := range r.OwnerRoles {
+			if !slices.Contains(roles, role) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// CanGrantOwnerRoles allows subjects who could grant every global role of the resource's
+// owner, as decided by data.CanGrantRole. The owner's permissions stand in for those of each
+// role, so subjects must also hold every permission the owner has.
+func CanGrantOwnerRoles() Rule {
+	return func(s Subject, r Resource) bool {
+		for _, role := range r.OwnerRoles {
+			if !data.CanGrantRole(s.Roles, s.Permissions, role, r.OwnerPermissions) {
+				return false
+			}
+		}
+		return data.CanGrantPermissions(s.Roles, s.Permissions, r.OwnerPermissions)
+	}
+}
+
+// All allows what every one of the rules allows.
+func All(rules ...Rule) Rule {
+	return func(s Subject, r Resource) bool {
+		for _, rule := range rules {
+			if !rule(s, r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any allows what at least one of the rules allows.
+func Any(rules ...Rule) Rule {
+	return func(s Subject, r Resource) bool {
+		for _, rule := range rules {
+			if rule(s, r) {
+				return true
+			}
+		}
+		return false
+	}
+}
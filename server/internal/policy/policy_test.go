@@ -0,0 +1,142 @@
+package policy_test
+
+import (
+	"testing"
+
+	"adcentra.ai/internal/data"
+	"adcentra.ai/internal/policy"
+)
+
+func TestDefault(t *testing.T) {
+	var (
+		superadmin = policy.Subject{
+			UserID: 1,
+			Roles:  data.Roles{data.RoleSuperAdmin},
+		}
+		admin = policy.Subject{
+			UserID:      2,
+			Roles:       data.Roles{data.RoleAdmin},
+			Permissions: data.Permissions{data.PermAdminsManage, data.PermUsersManage, data.PermRolesManage},
+		}
+		moderator = policy.Subject{
+			UserID:      3,
+			Roles:       data.Roles{data.RoleModerator},
+			Permissions: data.Permissions{data.PermUsersManage},
+		}
+		viewer = policy.Subject{
+			UserID:      4,
+			Roles:       data.Roles{data.RoleUser},
+			Permissions: data.Permissions{data.PermUsersView},
+		}
+		orgAdmin = policy.Subject{
+			UserID:         5,
+			Roles:          data.Roles{data.RoleUser},
+			OrganizationID: 10,
+			OrgPermissions: data.Permissions{data.PermUsersManage},
+		}
+		orgViewer = policy.Subject{
+			UserID:         6,
+			Roles:          data.Roles{data.RoleUser},
+			OrganizationID: 10,
+			OrgPermissions: data.Permissions{data.PermUsersView},
+		}
+		user = policy.Subject{
+			UserID: 7,
+			Roles:  data.Roles{data.RoleUser},
+		}
+
+		member = policy.Resource{
+			OwnerID:         100,
+			OwnerRoles:      data.Roles{data.RoleUser},
+			OrganizationIDs: []int64{10},
+		}
+		sharedMember = policy.Resource{
+			OwnerID:         101,
+			OwnerRoles:      data.Roles{data.RoleUser},
+			OrganizationIDs: []int64{10, 20},
+		}
+		outsider = policy.Resource{
+			OwnerID:         102,
+			OwnerRoles:      data.Roles{data.RoleUser},
+			OrganizationIDs: []int64{20},
+		}
+		moderatorMember = policy.Resource{
+			OwnerID:          103,
+			OwnerRoles:       data.Roles{data.RoleModerator},
+			OwnerPermissions: data.Permissions{data.PermUsersManage},
+			OrganizationIDs:  []int64{10},
+		}
+		adminUser = policy.Resource{
+			OwnerID:          104,
+			OwnerRoles:       data.Roles{data.RoleAdmin},
+			OwnerPermissions: data.Permissions{data.PermAdminsManage, data.PermUsersManage, data.PermRolesManage},
+		}
+		superadminUser = policy.Resource{
+			OwnerID:          105,
+			OwnerRoles:       data.Roles{data.RoleSuperAdmin},
+			OwnerPermissions: data.Permissions{data.PermAll},
+		}
+		self = policy.Resource{
+			OwnerID:    user.UserID,
+			OwnerRoles: user.Roles,
+		}
+	)
+
+	tests := []struct {
+		name     string
+		subject  policy.Subject
+		action   policy.Action
+		resource policy.Resource
+		want     bool
+	}{
+		{"superadmin manages superadmin", superadmin, policy.ManageUser, superadminUser, true},
+		{"superadmin manages account of admin", superadmin, policy.ManageUserAccount, adminUser, true},
+
+		{"admin views admin", admin, policy.ViewUser, adminUser, true},
+		{"admin manages user", admin, policy.ManageUser, member, true},
+		{"admin manages admin", admin, policy.ManageUser, adminUser, true},
+		{"admin manages account of user in several organizations", admin, policy.ManageUserAccount, sharedMember, true},
+		{"admin can't manage superadmin", admin, policy.ManageUser, superadminUser, false},
+		{"admin can't manage account of superadmin", admin, policy.ManageUserAccount, superadminUser, false},
+		{"admin can't revoke sessions of superadmin", admin, policy.RevokeUserSessions, superadminUser, false},
+
+		{"moderator manages user", moderator, policy.ManageUser, member, true},
+		{"moderator manages moderator", moderator, policy.ManageUser, moderatorMember, true},
+		{"moderator can't manage admin", moderator, policy.ManageUser, adminUser, false},
+		{"moderator can't revoke sessions of admin", moderator, policy.RevokeUserSessions, adminUser, false},
+
+		{"viewer views user", viewer, policy.ViewUser, outsider, true},
+		{"viewer can't manage user", viewer, policy.ManageUser, outsider, false},
+
+		{"org admin views member", orgAdmin, policy.ViewUser, member, true},
+		{"org admin manages member", orgAdmin, policy.ManageUser, member, true},
+		{"org admin manages member of several organizations", orgAdmin, policy.ManageUser, sharedMember, true},
+		{"org admin revokes sessions of member", orgAdmin, policy.RevokeUserSessions, member, true},
+		{"org admin manages account of member", orgAdmin, policy.ManageUserAccount, member, true},
+		{"org admin can't manage account of member of several organizations", orgAdmin, policy.ManageUserAccount, sharedMember, false},
+		{"org admin can't view outsider", orgAdmin, policy.ViewUser, outsider, false},
+		{"org admin can't manage outsider", orgAdmin, policy.ManageUser, outsider, false},
+		{"org admin can't manage member with global role", orgAdmin, policy.ManageUser, moderatorMember, false},
+		{"org admin can't view member with global role", orgAdmin, policy.ViewUser, moderatorMember, false},
+
+		{"org viewer views member", orgViewer, policy.ViewUser, member, true},
+		{"org viewer can't manage member", orgViewer, policy.ManageUser, member, false},
+		{"org viewer can't revoke sessions of member", orgViewer, policy.RevokeUserSessions, member, false},
+
+		{"user revokes own sessions", user, policy.RevokeUserSessions, self, true},
+		{"user can't view self through the admin endpoints", user, policy.ViewUser, self, false},
+		{"user can't manage self through the admin endpoints", user, policy.ManageUser, self, false},
+		{"user can't revoke sessions of others", user, policy.RevokeUserSessions, member, false},
+	}
+
+	p := policy.Default()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.Can(tt.subject, tt.action, tt.resource)
+			if got != tt.want {
+				t.Errorf("Can(%q) = %t, want %t", tt.action, got, tt.want)
+			}
+		})
+	}
+}
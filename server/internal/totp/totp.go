@@ -0,0 +1,96 @@
+// Package totp implements time-based one-time passwords as described in RFC 6238, using
+// the defaults understood by common authenticator apps (HMAC-SHA1, 6 digits, 30 seconds).
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/url"
+	"time"
+)
+
+const (
+	Digits = 6
+	Period = 30 * time.Second
+
+	// Number of time steps before and after the current one that are still accepted,
+	// to tolerate clock drift between the server and the authenticator.
+	skew = 1
+
+	secretSize = 20
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// modulus truncates codes to Digits decimal digits.
+var modulus = uint32(math.Pow10(Digits))
+
+// GenerateSecret returns a new random shared secret.
+func GenerateSecret() []byte {
+	secret := make([]byte, secretSize)
+	rand.Read(secret)
+	return secret
+}
+
+// EncodeSecret returns the base32 form of the secret that users can type into an authenticator.
+func EncodeSecret(secret []byte) string {
+	return b32.EncodeToString(secret)
+}
+
+// ProvisioningURI returns an otpauth:// URI which authenticator apps can import, usually via a QR code.
+func ProvisioningURI(issuer, account string, secret []byte) string {
+	query := url.Values{}
+	query.Set("secret", EncodeSecret(secret))
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+
+	return u.String()
+}
+
+// Validate checks the code against the secret at time t. Only time steps after lastUsedStep
+// are accepted, so that a code that was used already can't be replayed within its validity
+// window. On success it returns the time step that matched, which callers should persist as
+// the new last used step.
+func Validate(secret []byte, code string, t time.Time, lastUsedStep int64) (int64, bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	current := t.Unix() / int64(Period.Seconds())
+	for step := max(current-skew, lastUsedStep+1); step <= current+skew; step++ {
+		if hmac.Equal([]byte(generateCode(secret, step)), []byte(code)) {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+func generateCode(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation as defined in RFC 4226, section 5.3.
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, value%modulus)
+}
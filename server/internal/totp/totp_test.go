@@ -0,0 +1,107 @@
+package totp_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"adcentra.ai/internal/totp"
+)
+
+// The SHA-1 secret of the test vectors in RFC 6238, Appendix B.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestValidateRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; 6-digit codes are their last 6 digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			step, ok := totp.Validate(rfcSecret, tt.code, time.Unix(tt.unix, 0), 0)
+			if !ok {
+				t.Fatalf("Validate(%q) at %d failed", tt.code, tt.unix)
+			}
+			if want := tt.unix / 30; step != want {
+				t.Errorf("Validate() step = %d, want %d", step, want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	// "005924" is the code of step 41152263, from 1234567890 - 30 to 1234567890 + 30.
+	const code = "005924"
+	issued := time.Unix(1234567890, 0)
+	step := issued.Unix() / 30
+
+	tests := []struct {
+		name         string
+		code         string
+		at           time.Time
+		lastUsedStep int64
+		want         bool
+	}{
+		{"current step", code, issued, 0, true},
+		{"previous step within skew", code, issued.Add(30 * time.Second), 0, true},
+		{"next step within skew", code, issued.Add(-30 * time.Second), 0, true},
+		{"too old", code, issued.Add(60 * time.Second), 0, false},
+		{"too early", code, issued.Add(-60 * time.Second), 0, false},
+		{"replayed", code, issued, step, false},
+		{"replayed in the next step", code, issued.Add(30 * time.Second), step, false},
+		{"older than a used code", code, issued, step + 1, false},
+		{"after the previous code was used", code, issued, step - 1, true},
+		{"wrong code", "005925", issued, 0, false},
+		{"too short", "05924", issued, 0, false},
+		{"too long", "0005924", issued, 0, false},
+		{"empty", "", issued, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := totp.Validate(rfcSecret, tt.code, tt.at, tt.lastUsedStep)
+			if ok != tt.want {
+				t.Fatalf("Validate(%q) = %t, want %t", tt.code, ok, tt.want)
+			}
+			if ok && got != step {
+				t.Errorf("Validate() step = %d, want %d", got, step)
+			}
+		})
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	secret := totp.GenerateSecret()
+
+	u, err := url.Parse(totp.ProvisioningURI("Adcentra", "jane@example.com", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Adcentra:jane@example.com" {
+		t.Errorf("ProvisioningURI() = %s, want otpauth://totp/Adcentra:jane@example.com", u)
+	}
+
+	query := u.Query()
+	want := map[string]string{
+		"secret":    totp.EncodeSecret(secret),
+		"issuer":    "Adcentra",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("ProvisioningURI() %s = %q, want %q", key, got, value)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package validator
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = sync.OnceValue(func() map[string]struct{} {
+	passwords := make(map[string]struct{})
+	for line := range strings.Lines(commonPasswordsFile) {
+		if line = strings.TrimSpace(line); line != "" {
+			passwords[line] = struct{}{}
+		}
+	}
+	return passwords
+})
+
+// leetReplacer undoes the usual letter substitutions, such as "p@ssw0rd" for "password".
+var leetReplacer = strings.NewReplacer("@", "a", "4", "a", "3", "e", "0", "o", "$", "s", "5", "s", "7", "t")
+
+// trimPadding removes the digits and symbols around a word, such as "Summer2024!" for
+// "summer".
+func trimPadding(s string) string {
+	return strings.TrimFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
+
+// CommonPassword reports whether the password is one of the most commonly used ones. Case,
+// letter substitutions and digits or symbols added around it don't make a common password
+// any less common.
+func CommonPassword(password string) bool {
+	lower := strings.ToLower(password)
+
+	candidates := []string{
+		lower,
+		trimPadding(lower),
+		leetReplacer.Replace(trimPadding(lower)),
+		trimPadding(leetReplacer.Replace(lower)),
+	}
+
+	passwords := commonPasswords()
+	for _, candidate := range candidates {
+		if _, ok := passwords[candidate]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SimilarTo reports whether the password is built from any of the values, such as the
+// user's email address or name. It is when it contains a word of 4 or more characters of a
+// value, or when a value contains the password. Only the local part of email addresses is
+// considered.
+func SimilarTo(password string, values ...string) bool {
+	lower := strings.ToLower(password)
+	passwords := []string{lower, leetReplacer.Replace(lower)}
+
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			value = local
+		}
+		if value == "" {
+			continue
+		}
+
+		words := strings.FieldsFunc(value, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		words = append(words, strings.Join(words, ""))
+
+		for _, p := range passwords {
+			if strings.Contains(value, p) {
+				return true
+			}
+			for _, word := range words {
+				if len([]rune(word)) >= 4 && strings.Contains(p, word) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// BreachedPasswords looks up breached passwords by range, the way the Pwned Passwords API of
+// Have I Been Pwned does: given the first 5 hex characters of a SHA-1 hash, Range returns
+// the remaining 35 characters of every breached password hash with that prefix, mapped to
+// the number of times it was seen. Only the prefix leaves the process, so the password
+// itself is never disclosed.
+type BreachedPasswords interface {
+	Range(ctx context.Context, prefix string) (map[string]int, error)
+}
+
+// BreachCount returns the number of times the password was seen in data breaches.
+func BreachCount(ctx context.Context, source BreachedPasswords, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := source.Range(ctx, hash[:5])
+	if err != nil {
+		return 0, err
+	}
+	return suffixes[hash[5:]], nil
+}
+
+// HIBPRange queries the Pwned Passwords range API. Responses are padded, so that their size
+// doesn't give the prefix away either.
+type HIBPRange struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewHIBPRange() *HIBPRange {
+	return &HIBPRange{
+		BaseURL: "https://api.pwnedpasswords.com",
+		Client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (h *HIBPRange) Range(ctx context.Context, prefix string) (map[string]int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.BaseURL+"/range/"+prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Add-Padding", "true")
+	req.Header.Set("User-Agent", "adcentra")
+
+	res, err := h.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pwned passwords range request failed with status %d", res.StatusCode)
+	}
+
+	suffixes := make(map[string]int)
+
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		suffix, count, err := parseRangeLine(strings.TrimSpace(scanner.Text()), 35)
+		if err != nil {
+			return nil, err
+		}
+		// Padding entries have a count of 0.
+		if count > 0 {
+			suffixes[suffix] = count
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return suffixes, nil
+}
+
+// FileRange serves ranges from a local file with one SHA-1 hash per line, in the
+// HASH:COUNT format of the Pwned Passwords downloads. The count may be left out, in which
+// case it is 1. It is meant for development and tests, where the API shouldn't be called.
+type FileRange struct {
+	ranges map[string]map[string]int
+}
+
+func NewFileRange(path string) (*FileRange, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	f := &FileRange{ranges: make(map[string]map[string]int)}
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, count, err := parseRangeLine(line, 40)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+
+		prefix, suffix := hash[:5], hash[5:]
+		if f.ranges[prefix] == nil {
+			f.ranges[prefix] = make(map[string]int)
+		}
+		f.ranges[prefix][suffix] += count
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *FileRange) Range(ctx context.Context, prefix string) (map[string]int, error) {
+	return f.ranges[strings.ToUpper(prefix)], nil
+}
+
+// parseRangeLine parses a HASH:COUNT line whose hash, or hash suffix, is n hex characters
+// long. A missing count is 1.
+func parseRangeLine(line string, n int) (string, int, error) {
+	hash, countText, hasCount := strings.Cut(line, ":")
+	hash = strings.ToUpper(hash)
+
+	if len(hash) != n || strings.Trim(hash, "0123456789ABCDEF") != "" {
+		return "", 0, fmt.Errorf("invalid hash %q", hash)
+	}
+
+	if !hasCount {
+		return hash, 1, nil
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(countText))
+	if err != nil || count < 0 {
+		return "", 0, fmt.Errorf("invalid count %q", countText)
+	}
+	return hash, count, nil
+}
@@ -0,0 +1,298 @@
+package validator_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"adcentra.ai/internal/validator"
+)
+
+func TestCommonPassword(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"PASSWORD", true},
+		{"Password1!", true},
+		{"P@ssw0rd", true},
+		{"!!P@ssw0rd2024", true},
+		{"Summer2024!", true},
+		{"123456", true},
+		{"qwerty123", true},
+		{"correct horse battery staple", false},
+		{"Tr0ub4dor&3x", false},
+		{"passwordx", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := validator.CommonPassword(tt.password); got != tt.want {
+				t.Errorf("CommonPassword(%q) = %t, want %t", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		values   []string
+		want     bool
+	}{
+		{"contains email local part", "jane.doe1984!", []string{"jane.doe@example.com"}, true},
+		{"short word of email local part is ignored", "Doesville#9", []string{"jane.doe@example.com"}, false},
+		{"contains word of name", "Kowalski-2024", []string{"Anna Kowalski"}, true},
+		{"contains joined name", "AnnaKowalski!", []string{"Anna Kowalski"}, true},
+		{"digit for i isn't undone", "K0w@lsk1rules", []string{"Anna Kowalski"}, false},
+		{"contains word with letter substitutions", "k0w@lski!99", []string{"Anna Kowalski"}, true},
+		{"contained in value", "kowal", []string{"Anna Kowalski"}, true},
+		{"short words are ignored", "Ann-Bo-42-xyz!", []string{"Ann Bo"}, false},
+		{"domain is ignored", "example-Rocks-9", []string{"jane@example.com"}, false},
+		{"case is ignored", "ANNA-likes-tea", []string{"anna.smith@example.com"}, true},
+		{"unrelated", "Tr0ub4dor&3x", []string{"jane.doe@example.com", "Jane Doe"}, false},
+		{"blank values", "Tr0ub4dor&3x", []string{"", "  "}, false},
+		{"no values", "Tr0ub4dor&3x", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validator.SimilarTo(tt.password, tt.values...); got != tt.want {
+				t.Errorf("SimilarTo(%q, %q) = %t, want %t", tt.password, tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+// sha1Hex returns the uppercase hex SHA-1 hash of the password, as Pwned Passwords lists it.
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+type stubRange struct {
+	ranges map[string]map[string]int
+	err    error
+	prefix string
+}
+
+func (s *stubRange) Range(ctx context.Context, prefix string) (map[string]int, error) {
+	s.prefix = prefix
+	return s.ranges[prefix], s.err
+}
+
+func TestBreachCount(t *testing.T) {
+	hash := sha1Hex("hunter2")
+	errUnavailable := errors.New("unavailable")
+
+	tests := []struct {
+		name     string
+		source   *stubRange
+		password string
+		want     int
+		wantErr  error
+	}{
+		{
+			name:     "breached",
+			source:   &stubRange{ranges: map[string]map[string]int{hash[:5]: {hash[5:]: 17, strings.Repeat("0", 35): 3}}},
+			password: "hunter2",
+			want:     17,
+		},
+		{
+			name:     "not in range",
+			source:   &stubRange{ranges: map[string]map[string]int{hash[:5]: {strings.Repeat("0", 35): 3}}},
+			password: "hunter2",
+			want:     0,
+		},
+		{
+			name:     "empty range",
+			source:   &stubRange{},
+			password: "hunter2",
+			want:     0,
+		},
+		{
+			name:     "source fails",
+			source:   &stubRange{err: errUnavailable},
+			password: "hunter2",
+			wantErr:  errUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validator.BreachCount(context.Background(), tt.source, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("BreachCount() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("BreachCount() = %d, want %d", got, tt.want)
+			}
+			if tt.source.prefix != hash[:5] {
+				t.Errorf("Range() prefix = %q, want %q", tt.source.prefix, hash[:5])
+			}
+		})
+	}
+}
+
+func TestFileRange(t *testing.T) {
+	breached, again, counted := sha1Hex("hunter2"), sha1Hex("letmein"), sha1Hex("trustno1")
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := strings.Join([]string{
+		"# comment",
+		"",
+		breached,
+		strings.ToLower(again) + ":2",
+		again + ":3",
+		counted + ": 42",
+	}, "\n")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := validator.NewFileRange(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{"hunter2", 1},
+		{"letmein", 5},
+		{"trustno1", 42},
+		{"correct horse battery staple", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			got, err := validator.BreachCount(context.Background(), source, tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("BreachCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFileRangeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short hash", "21BD12DC"},
+		{"not hex", strings.Repeat("Z", 40)},
+		{"invalid count", sha1Hex("hunter2") + ":many"},
+		{"negative count", sha1Hex("hunter2") + ":-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "breached.txt")
+			if err := os.WriteFile(path, []byte(tt.line+"\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := validator.NewFileRange(path); err == nil {
+				t.Errorf("NewFileRange() with %q succeeded, want an error", tt.line)
+			}
+		})
+	}
+}
+
+func TestHIBPRange(t *testing.T) {
+	hash := sha1Hex("hunter2")
+	prefix, suffix := hash[:5], hash[5:]
+	other := strings.Repeat("A", 35)
+	padding := strings.Repeat("B", 35)
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    map[string]int
+		wantErr bool
+	}{
+		{
+			name:   "padded response",
+			status: http.StatusOK,
+			body:   suffix + ":17\r\n" + strings.ToLower(other) + ":3\r\n" + padding + ":0\r\n",
+			want:   map[string]int{suffix: 17, other: 3},
+		},
+		{
+			name:   "empty response",
+			status: http.StatusOK,
+			want:   map[string]int{},
+		},
+		{
+			name:    "malformed line",
+			status:  http.StatusOK,
+			body:    suffix + ":17\r\nnot a hash\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "malformed count",
+			status:  http.StatusOK,
+			body:    suffix + ":lots\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "error status",
+			status:  http.StatusServiceUnavailable,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/range/"+prefix {
+					t.Errorf("path = %q, want %q", r.URL.Path, "/range/"+prefix)
+				}
+				if r.Header.Get("Add-Padding") != "true" {
+					t.Errorf("Add-Padding header = %q, want true", r.Header.Get("Add-Padding"))
+				}
+
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			source := validator.NewHIBPRange()
+			source.BaseURL = server.URL
+
+			got, err := source.Range(context.Background(), prefix)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Range() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Range() error = %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Range() = %v, want %v", got, tt.want)
+			}
+			for s, count := range tt.want {
+				if got[s] != count {
+					t.Errorf("Range()[%s] = %d, want %d", s, got[s], count)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package validator
 
 import (
+	"net/url"
 	"regexp"
 	"slices"
 	"strings"
@@ -16,6 +17,7 @@ var (
 	HasUpperRX      = regexp.MustCompile(`[A-Z]`)
 	HasDigitRX      = regexp.MustCompile(`\d`)
 	HasSpecialRX    = regexp.MustCompile(`[!@#$&*]`)
+	NumericRX       = regexp.MustCompile(`^\d+$`)
 )
 
 type Validator struct {
@@ -93,6 +95,15 @@ func MaxBytes(value string, n int) bool {
 	return len(value) <= n
 }
 
+// ValidURL reports whether value is an absolute http or https URL.
+func ValidURL(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
 func Unique[T comparable](values []T) bool {
 	uniqueValues := make(map[T]bool)
 
@@ -13,6 +13,27 @@ const { t, locale } = i18n.global
 
 const API_BASE_URL = import.meta.env.VITE_API_BASE_URL || 'http://localhost:5500/v1'
 
+const CSRF_TOKEN_COOKIE = 'csrf_token'
+const CSRF_TOKEN_HEADER = 'X-CSRF-Token'
+
+// The refresh token cookie only counts together with its CSRF token in the X-CSRF-Token
+// header. Login and refresh responses set it in a cookie, readable when the SPA shares the
+// API's cookie domain, and in a response header, which is kept for when it doesn't.
+function rememberCsrfToken(response: Response | null | undefined) {
+  const token = response?.headers.get(CSRF_TOKEN_HEADER)
+  if (token) {
+    useAuthStore().setCsrfToken(token)
+  }
+}
+
+function csrfToken(): string | null {
+  const cookie = document.cookie.split('; ').find((c) => c.startsWith(`${CSRF_TOKEN_COOKIE}=`))
+  if (cookie) {
+    return decodeURIComponent(cookie.slice(CSRF_TOKEN_COOKIE.length + 1))
+  }
+  return useAuthStore().csrfToken
+}
+
 // Custom 'useFetch' composable instance with authentication
 export const useAuthFetch = createFetch({
   baseUrl: API_BASE_URL,
@@ -74,6 +95,8 @@ export const useAuthFetch = createFetch({
     },
 
     afterFetch(ctx) {
+      rememberCsrfToken(ctx.response)
+
       let data
       if (ctx.data) {
         data = camelKeys(ctx.data)
@@ -158,20 +181,28 @@ async function refreshToken(): Promise<void> {
   const authStore = useAuthStore()
 
   try {
+    const headers: Record<string, string> = {
+      'Content-Type': 'application/json',
+      'Accept-Language': locale.value,
+    }
+    const token = csrfToken()
+    if (token) {
+      headers[CSRF_TOKEN_HEADER] = token
+    }
+
     // Use native fetch for the refresh call to avoid circular dependency
     const response = await fetch(`${API_BASE_URL}/tokens/refresh`, {
       method: 'POST',
       credentials: 'include', // Include HTTP-only refresh token cookie
-      headers: {
-        'Content-Type': 'application/json',
-        'Accept-Language': locale.value,
-      },
+      headers,
     })
 
     if (!response.ok) {
       throw new Error(t('errors.failedToRefreshToken'))
     }
 
+    rememberCsrfToken(response)
+
     const rawData = await response.json()
     const data = RefreshTokenResponseSchema.parse(camelKeys(rawData))
 
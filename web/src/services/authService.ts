@@ -34,9 +34,9 @@ export async function login(credentials: LoginRequest): Promise<LoginResponse> {
   }
 }
 
-export async function logout(scope: 'local' | 'global' | 'others' = 'local') {
+export async function logout() {
   try {
-    await useAuthFetch(`/tokens/authentication?scope=${scope}`).delete()
+    await useAuthFetch('/tokens/authentication').delete()
   } catch (error) {
     console.warn('Logout API call failed:', error)
     throw new ServiceError(t('errors.somethingWentWrong'))
@@ -12,6 +12,9 @@ export const useAuthStore = defineStore(
     const user = ref<User | null>(null)
     const accessToken = ref<string | null>(null)
     const tokenExpiresAt = ref<Dayjs | null>(null)
+    // CSRF token of the refresh token cookie, from the X-CSRF-Token response header. Needed
+    // when the API is on a domain whose csrf_token cookie can't be read from here.
+    const csrfToken = ref<string | null>(null)
 
     // Getters
     const isAuthenticated = computed(() => !!user.value && !!accessToken.value)
@@ -42,10 +45,15 @@ export const useAuthStore = defineStore(
       tokenExpiresAt.value = authToken.expiry // Already transformed to Dayjs
     }
 
+    function setCsrfToken(token: string) {
+      csrfToken.value = token
+    }
+
     function clearAuth() {
       user.value = null
       accessToken.value = null
       tokenExpiresAt.value = null
+      csrfToken.value = null
     }
 
     function updateUser(userData: Partial<User>) {
@@ -59,6 +67,7 @@ export const useAuthStore = defineStore(
       user,
       accessToken,
       tokenExpiresAt,
+      csrfToken,
 
       // Getters
       isAuthenticated,
@@ -69,13 +78,14 @@ export const useAuthStore = defineStore(
       // Actions
       setAuth,
       setAccessToken,
+      setCsrfToken,
       clearAuth,
       updateUser,
     }
   },
   {
     persist: {
-      pick: ['user', 'accessToken', 'tokenExpiresAt'],
+      pick: ['user', 'accessToken', 'tokenExpiresAt', 'csrfToken'],
       afterHydrate: (context) => {
         if (context.store.tokenExpiresAt && typeof context.store.tokenExpiresAt == 'string') {
           context.store.tokenExpiresAt = dayjs(context.store.tokenExpiresAt)